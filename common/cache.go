@@ -0,0 +1,273 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	cacheDirName    = "G-itemViewer"
+	DefaultCacheAge = 6 * time.Hour
+)
+
+// DataCache is the on-disk cache shared by the game data loaders
+var DataCache = NewCache(defaultCacheDir())
+
+// CacheMeta holds the freshness information stored next to a cached body
+type CacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// Age returns how long ago the cached body was fetched or revalidated
+func (m CacheMeta) Age() time.Duration {
+	return time.Since(m.FetchedAt)
+}
+
+// Cache stores downloaded game data on disk. Entries younger than MaxAge are
+// served directly, older ones are served immediately and revalidated in the
+// background. In Offline mode only the cached copy is used; it can be
+// switched while background fetches are running. Downloads go through
+// Fetcher, or DefaultFetcher when it is nil.
+type Cache struct {
+	Dir     string
+	MaxAge  time.Duration
+	Offline atomic.Bool
+	Fetcher *Fetcher
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{
+		Dir:        dir,
+		MaxAge:     DefaultCacheAge,
+		refreshing: make(map[string]bool),
+	}
+}
+
+// ConfigDir returns the directory user settings are stored in
+func ConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, cacheDirName)
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, cacheDirName)
+}
+
+// Get returns the body for url, using the cache entry called name where
+// possible. validate is run on every downloaded body before it is cached so a
+// broken response never replaces the last good copy.
+func (c *Cache) Get(name string, url string, validate func([]byte) error) ([]byte, error) {
+	body, meta, cacheErr := c.read(name)
+	if c.Offline.Load() {
+		if cacheErr != nil {
+			return nil, fmt.Errorf("offline mode: no cached copy of %s: %w", name, cacheErr)
+		}
+		return body, nil
+	}
+
+	if cacheErr == nil && meta.URL == url {
+		if meta.Age() >= c.MaxAge {
+			go func() {
+				if err := c.revalidate(name, url, meta, validate); err != nil {
+					log.Printf("cache: failed to revalidate %s: %v", name, err)
+				}
+			}()
+		}
+		return body, nil
+	}
+
+	fresh, freshMeta, err := c.fetch(url, nil, validate)
+	if err != nil {
+		if cacheErr == nil {
+			log.Printf("cache: using last good copy of %s: %v", name, err)
+			return body, nil
+		}
+		return nil, err
+	}
+
+	if err := c.write(name, fresh, freshMeta); err != nil {
+		log.Printf("cache: failed to store %s: %v", name, err)
+	}
+	return fresh, nil
+}
+
+// Refresh is like Get but revalidates a cached entry against the server
+// before returning it, regardless of its age. The cached copy is still served
+// when revalidation fails.
+func (c *Cache) Refresh(name string, url string, validate func([]byte) error) ([]byte, error) {
+	_, meta, cacheErr := c.read(name)
+	if c.Offline.Load() || cacheErr != nil || meta.URL != url {
+		return c.Get(name, url, validate)
+	}
+
+	if err := c.revalidate(name, url, meta, validate); err != nil {
+		log.Printf("cache: using last good copy of %s: %v", name, err)
+	}
+	body, _, err := c.read(name)
+	return body, err
+}
+
+// Meta returns the stored freshness information for the entry called name
+func (c *Cache) Meta(name string) (CacheMeta, bool) {
+	var meta CacheMeta
+	data, err := ioutil.ReadFile(c.metaPath(name))
+	if err != nil {
+		return meta, false
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, false
+	}
+	return meta, true
+}
+
+// revalidate makes a conditional request for a cached entry and stores the
+// result. It does nothing if the entry is already being revalidated.
+func (c *Cache) revalidate(name string, url string, meta CacheMeta, validate func([]byte) error) error {
+	c.mu.Lock()
+	if c.refreshing[name] {
+		c.mu.Unlock()
+		return nil
+	}
+	c.refreshing[name] = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.refreshing, name)
+		c.mu.Unlock()
+	}()
+
+	body, newMeta, err := c.fetch(url, &meta, validate)
+	if err != nil {
+		return err
+	}
+
+	if body == nil {
+		meta.FetchedAt = newMeta.FetchedAt
+		return c.writeMeta(name, meta)
+	}
+	return c.write(name, body, newMeta)
+}
+
+// fetch downloads url. When prev is set a conditional request is made and a
+// nil body is returned if the server reports the cached copy is still current.
+func (c *Cache) fetch(url string, prev *CacheMeta, validate func([]byte) error) ([]byte, CacheMeta, error) {
+	meta := CacheMeta{URL: url}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, meta, err
+	}
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+
+	fetcher := c.Fetcher
+	if fetcher == nil {
+		fetcher = DefaultFetcher
+	}
+	resp, body, err := fetcher.Do(req)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	meta.FetchedAt = time.Now()
+	if body == nil {
+		if prev == nil {
+			return nil, meta, &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		return nil, meta, nil
+	}
+	if validate != nil {
+		if err := validate(body); err != nil {
+			return nil, meta, &ParseError{URL: url, Err: err}
+		}
+	}
+
+	meta.ETag = resp.Header.Get("ETag")
+	meta.LastModified = resp.Header.Get("Last-Modified")
+	return body, meta, nil
+}
+
+func (c *Cache) read(name string) ([]byte, CacheMeta, error) {
+	meta, ok := c.Meta(name)
+	if !ok {
+		return nil, meta, fmt.Errorf("no cache entry for %s", name)
+	}
+	body, err := ioutil.ReadFile(c.bodyPath(name))
+	if err != nil {
+		return nil, meta, err
+	}
+	return body, meta, nil
+}
+
+func (c *Cache) write(name string, body []byte, meta CacheMeta) error {
+	if err := writeFileAtomic(c.bodyPath(name), body); err != nil {
+		return err
+	}
+	return c.writeMeta(name, meta)
+}
+
+func (c *Cache) writeMeta(name string, meta CacheMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.metaPath(name), data)
+}
+
+func (c *Cache) bodyPath(name string) string {
+	return filepath.Join(c.Dir, name+".dat")
+}
+
+func (c *Cache) metaPath(name string) string {
+	return filepath.Join(c.Dir, name+".meta.json")
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+// so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,170 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// etagServer serves body with an ETag derived from its version and answers
+// conditional requests for the current version with 304
+type etagServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	version     int
+	requests    atomic.Int32
+	notModified atomic.Int32
+}
+
+func newETagServer(t *testing.T) *etagServer {
+	t.Helper()
+	s := &etagServer{version: 1}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		version := s.version
+		s.mu.Unlock()
+
+		etag := fmt.Sprintf(`"v%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, "furni_a_name=Version %d", version)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *etagServer) publish(version int) {
+	s.mu.Lock()
+	s.version = version
+	s.mu.Unlock()
+}
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	cache := NewCache(t.TempDir())
+	cache.Fetcher = newTestFetcher()
+	return cache
+}
+
+func TestCacheRevalidatesWithETag(t *testing.T) {
+	srv := newETagServer(t)
+	cache := newTestCache(t)
+
+	body, err := cache.Get("texts", srv.URL, validateExternalTexts)
+	if err != nil || string(body) != "furni_a_name=Version 1" {
+		t.Fatalf("first get: %q, %v", body, err)
+	}
+	first, _ := cache.Meta("texts")
+	if first.ETag != `"v1"` {
+		t.Errorf("etag %q not stored", first.ETag)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	body, err = cache.Refresh("texts", srv.URL, validateExternalTexts)
+	if err != nil || string(body) != "furni_a_name=Version 1" {
+		t.Fatalf("refresh: %q, %v", body, err)
+	}
+	if srv.notModified.Load() != 1 {
+		t.Errorf("want a 304 for the unchanged body, got %d", srv.notModified.Load())
+	}
+	revalidated, _ := cache.Meta("texts")
+	if !revalidated.FetchedAt.After(first.FetchedAt) || revalidated.ETag != `"v1"` {
+		t.Errorf("304 did not renew the entry: %+v -> %+v", first, revalidated)
+	}
+
+	srv.publish(2)
+	body, err = cache.Refresh("texts", srv.URL, validateExternalTexts)
+	if err != nil || string(body) != "furni_a_name=Version 2" {
+		t.Fatalf("refresh after a change: %q, %v", body, err)
+	}
+	if meta, _ := cache.Meta("texts"); meta.ETag != `"v2"` {
+		t.Errorf("etag %q after a change", meta.ETag)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	srv := newETagServer(t)
+	cache := newTestCache(t)
+	cache.MaxAge = time.Hour
+
+	if _, err := cache.Get("texts", srv.URL, validateExternalTexts); err != nil {
+		t.Fatal(err)
+	}
+	srv.publish(2)
+	body, err := cache.Get("texts", srv.URL, validateExternalTexts)
+	if err != nil || string(body) != "furni_a_name=Version 1" {
+		t.Fatalf("fresh entry: %q, %v", body, err)
+	}
+	if srv.requests.Load() != 1 {
+		t.Errorf("fresh entry was fetched again, %d requests", srv.requests.Load())
+	}
+
+	// A different URL for the same entry is fetched right away
+	body, err = cache.Get("texts", srv.URL+"/moved", validateExternalTexts)
+	if err != nil || string(body) != "furni_a_name=Version 2" {
+		t.Errorf("moved entry: %q, %v", body, err)
+	}
+}
+
+func TestCacheServesStaleWhileRevalidating(t *testing.T) {
+	srv := newETagServer(t)
+	cache := newTestCache(t)
+	cache.MaxAge = 0
+
+	if _, err := cache.Get("texts", srv.URL, validateExternalTexts); err != nil {
+		t.Fatal(err)
+	}
+	srv.publish(2)
+	body, err := cache.Get("texts", srv.URL, validateExternalTexts)
+	if err != nil || string(body) != "furni_a_name=Version 1" {
+		t.Fatalf("expired entry was not served straight away: %q, %v", body, err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		body, _, err := cache.read("texts")
+		if err == nil && string(body) == "furni_a_name=Version 2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background revalidation did not store the new body, have %q", body)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	srv := newETagServer(t)
+	cache := newTestCache(t)
+	cache.MaxAge = 0
+	if _, err := cache.Get("texts", srv.URL, validateExternalTexts); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Offline.Store(true)
+	srv.publish(2)
+	for name, get := range map[string]func(string, string, func([]byte) error) ([]byte, error){
+		"get":     cache.Get,
+		"refresh": cache.Refresh,
+	} {
+		body, err := get("texts", srv.URL, validateExternalTexts)
+		if err != nil || string(body) != "furni_a_name=Version 1" {
+			t.Errorf("%s: %q, %v", name, body, err)
+		}
+	}
+	if _, err := cache.Get("furnidata", srv.URL, nil); err == nil {
+		t.Error("offline get of an uncached entry succeeded")
+	}
+	if srv.requests.Load() != 1 {
+		t.Errorf("offline cache made %d requests", srv.requests.Load())
+	}
+}
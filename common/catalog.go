@@ -0,0 +1,304 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+	"xabbo.b7c.io/goearth/shockwave/room"
+)
+
+// Catalog holds the furni data, external texts and prices used to enrich
+// inventory and room items
+type Catalog struct {
+	hotel         Hotel
+	furniData     map[string]FurniData
+	externalTexts map[string]string
+	apiItems      map[string]APIItem
+	apiPrices     *APIPriceProvider
+	variants      map[string][]string
+	status        CatalogStatus
+}
+
+// CatalogStatus reports whether each data set of a catalog loaded
+type CatalogStatus struct {
+	FurniData     DataStatus
+	ExternalTexts DataStatus
+	Prices        DataStatus
+}
+
+// NewCatalog loads every data set from src. A catalog is always returned so
+// that a failure in one data set still leaves the others usable.
+func NewCatalog(src GameDataSource) (*Catalog, error) {
+	c := &Catalog{}
+	var errs []error
+
+	furni, err := src.FurniData()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("furni data: %w", err))
+	}
+	c.setFurniData(furni)
+	c.status.FurniData = NewDataStatus(err)
+
+	texts, err := src.ExternalTexts()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("external texts: %w", err))
+	}
+	c.externalTexts = texts
+	c.status.ExternalTexts = NewDataStatus(err)
+
+	items, err := src.APIItems()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("api items: %w", err))
+	}
+	c.setAPIItems(items)
+	c.status.Prices = NewDataStatus(err)
+
+	return c, errors.Join(errs...)
+}
+
+func (c *Catalog) clone() *Catalog {
+	clone := *c
+	return &clone
+}
+
+func (c *Catalog) setAPIItems(items map[string]APIItem) {
+	c.apiItems = items
+	c.apiPrices = NewAPIPriceProvider(items, defaultPriceMap)
+}
+
+// Status reports which data sets loaded and why the others are unavailable
+func (c *Catalog) Status() CatalogStatus {
+	return c.status
+}
+
+// Hotel returns the hotel the catalog was loaded for
+func (c *Catalog) Hotel() Hotel {
+	return c.hotel
+}
+
+func (c *Catalog) iconBaseURL() string {
+	if c.hotel.IconBaseURL != "" {
+		return c.hotel.IconBaseURL
+	}
+	return IconBaseURL
+}
+
+// defaultWallRevision is used for wall item icons that have no furnidata
+// entry
+const defaultWallRevision = "56783"
+
+// Furni returns the furnidata entry for a floor ("S") or wall ("I") item.
+// Colour variants without an entry of their own use the base furni's entry.
+func (c *Catalog) Furni(class string, itemType string) (FurniData, bool) {
+	furni, ok := c.furniData[class]
+	if !ok {
+		furni, ok = c.furniData[BaseClass(class)]
+	}
+	if !ok || furni.WallItem != (itemType == "I") {
+		return FurniData{}, false
+	}
+	return furni, true
+}
+
+// textKeys returns the external texts keys that may hold an item's name or
+// description, most specific first. Posters share one class and are told
+// apart by props.
+func textKeys(class string, itemType string, props string, suffix string) []string {
+	if itemType == "I" {
+		var keys []string
+		if props != "" {
+			keys = append(keys, fmt.Sprintf("poster_%s_%s", props, suffix))
+		}
+		return append(keys, fmt.Sprintf("wallitem_%s_%s", class, suffix))
+	}
+	return []string{fmt.Sprintf("furni_%s_%s", class, suffix)}
+}
+
+func (c *Catalog) lookupText(class string, itemType string, props string, suffix string) (string, bool) {
+	for _, key := range textKeys(class, itemType, props, suffix) {
+		if text, ok := c.externalTexts[key]; ok && text != "" {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// GetItemName returns the display name of an item, see ResolveName
+func (c *Catalog) GetItemName(class string, itemType string, props string) string {
+	return c.ResolveName(class, itemType, props).Text
+}
+
+// GetItemDescription returns the description of an item, see
+// ResolveDescription
+func (c *Catalog) GetItemDescription(class string, itemType string, props string) string {
+	return c.ResolveDescription(class, itemType, props).Text
+}
+
+// IconRef returns the revision and file name of an item's icon on the
+// hotel's image server
+func (c *Catalog) IconRef(classname string, itemType string, props string) (revision string, file string, ok bool) {
+	classnameForIcon := strings.ReplaceAll(classname, "*", "_")
+
+	if itemType == "I" {
+		revision = defaultWallRevision
+		if furni, ok := c.Furni(classname, "I"); ok && furni.Revision > 0 {
+			revision = fmt.Sprintf("%d", furni.Revision)
+		}
+
+		file = classnameForIcon
+		if classname == "poster" {
+			file = "poster" + props
+		}
+		return revision, file + "_icon.png", true
+	}
+
+	furni, ok := c.Furni(classname, "S")
+	if !ok {
+		return "", "", false
+	}
+	return fmt.Sprintf("%d", furni.Revision), classnameForIcon + "_icon.png", true
+}
+
+// GetIconURL returns the path the icon is served at by the local icon cache
+func (c *Catalog) GetIconURL(classname string, itemType string, props string) string {
+	revision, file, ok := c.IconRef(classname, itemType, props)
+	if !ok {
+		return ""
+	}
+	return IconPath(revision, file)
+}
+
+// RemoteIconURL returns the icon's URL on the hotel's image server, for
+// places the local icon cache cannot serve such as Discord embeds
+func (c *Catalog) RemoteIconURL(classname string, itemType string, props string) string {
+	revision, file, ok := c.IconRef(classname, itemType, props)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(c.iconBaseURL(), revision) + file
+}
+
+func (c *Catalog) GetHCValue(itemName string) HC {
+	price, _ := c.Price(PriceKey{Name: itemName})
+	return price.Value
+}
+
+// itemPrice values an item by classname and display name
+func (c *Catalog) itemPrice(class string, itemType string, props string, name string) Price {
+	price, _ := c.Price(PriceKey{
+		ClassName: class,
+		ItemType:  itemType,
+		Props:     props,
+		Name:      name,
+	})
+	return price
+}
+
+// InventoryGroupKey returns the key items are grouped by in the inventory.
+// Posters share one class and are told apart by props.
+func InventoryGroupKey(item inventory.Item) string {
+	if item.Type == "I" {
+		return fmt.Sprintf("%s_%s", item.Class, item.Props)
+	}
+	return item.Class
+}
+
+func (c *Catalog) EnrichInventoryItem(item inventory.Item) EnrichedInventoryItem {
+	name := c.ResolveName(item.Class, string(item.Type), item.Props)
+	furni, _ := c.Furni(item.Class, string(item.Type))
+	base, variant, _ := SplitVariant(item.Class)
+	price := c.itemPrice(item.Class, string(item.Type), item.Props, name.Text)
+	return EnrichedInventoryItem{
+		Item:        item,
+		Name:        name.Text,
+		NameSource:  name.Source,
+		Description: c.GetItemDescription(item.Class, string(item.Type), item.Props),
+		IconURL:     c.GetIconURL(item.Class, string(item.Type), item.Props),
+		HCValue:     price.Value,
+		PriceSource: price.Source,
+		Rare:        furni.Rare,
+		GroupKey:    InventoryGroupKey(item),
+		BaseClass:   base,
+		Variant:     variant,
+		Category:    furni.Category,
+		XDim:        furni.XDim,
+		YDim:        furni.YDim,
+		Colors:      furni.PartColors.Color,
+	}
+}
+
+func (c *Catalog) EnrichRoomObject(obj room.Object) EnrichedRoomObject {
+	furni, _ := c.Furni(obj.Class, "S")
+	name := c.ResolveName(obj.Class, "S", "")
+	base, variant, _ := SplitVariant(obj.Class)
+	price := c.itemPrice(obj.Class, "S", "", name.Text)
+	return EnrichedRoomObject{
+		Object:      obj,
+		Name:        name.Text,
+		NameSource:  name.Source,
+		Description: c.GetItemDescription(obj.Class, "S", ""),
+		IconURL:     c.GetIconURL(obj.Class, "S", ""),
+		HCValue:     price.Value,
+		PriceSource: price.Source,
+		Rare:        furni.Rare,
+		BaseClass:   base,
+		Variant:     variant,
+		Category:    furni.Category,
+		Width:       obj.Width,
+		Height:      obj.Height,
+		X:           obj.X,
+		Y:           obj.Y,
+		Direction:   obj.Direction,
+		Colors:      furni.PartColors.Color,
+	}
+}
+
+func (c *Catalog) EnrichRoomItem(item room.Item) EnrichedRoomItem {
+	furni, _ := c.Furni(item.Class, "I")
+	name := c.ResolveName(item.Class, "I", item.Type)
+	price := c.itemPrice(item.Class, "I", item.Type, name.Text)
+	return EnrichedRoomItem{
+		Item:        item,
+		Name:        name.Text,
+		NameSource:  name.Source,
+		Description: c.GetItemDescription(item.Class, "I", item.Type),
+		IconURL:     c.GetIconURL(item.Class, "I", item.Type),
+		HCValue:     price.Value,
+		PriceSource: price.Source,
+		Rare:        furni.Rare,
+		Category:    furni.Category,
+		Location:    item.Location,
+	}
+}
+
+// GetInventorySummary renders the inventory summary as plain text, most
+// valuable items first
+func (c *Catalog) GetInventorySummary(items map[int]inventory.Item) string {
+	return c.InventorySummary(items, DefaultSummaryOptions).String()
+}
+
+// GetRoomSummary renders the room summary as plain text, most valuable items
+// first
+func (c *Catalog) GetRoomSummary(objects map[int]room.Object, items map[int]room.Item) string {
+	return c.RoomSummary(objects, items, DefaultSummaryOptions).String()
+}
+
+func (c *Catalog) GetInventoryItemDetails(item inventory.Item) string {
+	name := c.GetItemName(item.Class, string(item.Type), item.Props)
+	return fmt.Sprintf("Name: %s\nID: %d\nType: %s\nClass: %s\nProps: %s\n",
+		name, item.ItemId, item.Type, item.Class, item.Props)
+}
+
+func (c *Catalog) GetRoomItemDetails(item room.Item) string {
+	name := c.GetItemName(item.Class, "I", item.Type)
+	return fmt.Sprintf("Name: %s\nID: %d\nClass: %s\nOwner: %s\nLocation: %s\nType: %s\n",
+		name, item.Id, item.Class, item.Owner, item.Location, item.Type)
+}
+
+func (c *Catalog) GetRoomObjectDetails(obj room.Object) string {
+	name := c.GetItemName(obj.Class, "S", "")
+	return fmt.Sprintf("Name: %s\nID: %d\nClass: %s\nPosition: (%d, %d, %.2f)\nSize: %dx%d\nDirection: %d\n",
+		name, obj.Id, obj.Class, obj.X, obj.Y, obj.Z, obj.Width, obj.Height, obj.Direction)
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"testing"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+	"xabbo.b7c.io/goearth/shockwave/room"
+)
+
+func loadFixtureCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := NewCatalog(NewFileSource("testdata/gamedata"))
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	return c
+}
+
+func TestCatalogStatus(t *testing.T) {
+	c := loadFixtureCatalog(t)
+	status := c.Status()
+	for name, s := range map[string]DataStatus{
+		"furni data":     status.FurniData,
+		"external texts": status.ExternalTexts,
+		"prices":         status.Prices,
+	} {
+		if !s.Available {
+			t.Errorf("%s not available: %+v", name, s)
+		}
+	}
+
+	c, err := NewCatalog(NewFileSource(t.TempDir()))
+	if err == nil {
+		t.Fatal("NewCatalog from an empty directory returned no error")
+	}
+	if c == nil || c.Status().FurniData.Available {
+		t.Fatalf("want a catalog with unavailable data, got %+v", c)
+	}
+}
+
+func TestEnrichInventoryItem(t *testing.T) {
+	c := loadFixtureCatalog(t)
+
+	tests := []struct {
+		item        inventory.Item
+		name        string
+		description string
+		value       HC
+		rare        bool
+		groupKey    string
+		iconURL     string
+	}{
+		{
+			item:        inventory.Item{ItemId: 1, Class: "throne", Type: "S"},
+			name:        "Throne",
+			description: "Important Habbos only",
+			value:       5000,
+			rare:        true,
+			groupKey:    "throne",
+			iconURL:     IconPath("20", "throne_icon.png"),
+		},
+		{
+			item:        inventory.Item{ItemId: 2, Class: "poster", Type: "I", Props: "5003"},
+			name:        "Purple Garland",
+			description: "Deck the halls",
+			value:       350,
+			groupKey:    "poster_5003",
+			iconURL:     IconPath("7", "poster5003_icon.png"),
+		},
+		{
+			item:     inventory.Item{ItemId: 3, Class: "chair_polyfon", Type: "S"},
+			name:     "Dining Chair",
+			value:    25,
+			groupKey: "chair_polyfon",
+			iconURL:  IconPath("5", "chair_polyfon_icon.png"),
+		},
+	}
+	for _, tt := range tests {
+		got := c.EnrichInventoryItem(tt.item)
+		if got.Name != tt.name || got.Description != tt.description {
+			t.Errorf("%s: name %q, description %q", tt.item.Class, got.Name, got.Description)
+		}
+		if got.HCValue != tt.value || got.Rare != tt.rare {
+			t.Errorf("%s: value %s, rare %v", tt.item.Class, got.HCValue, got.Rare)
+		}
+		if got.GroupKey != tt.groupKey || got.IconURL != tt.iconURL {
+			t.Errorf("%s: group key %q, icon %q", tt.item.Class, got.GroupKey, got.IconURL)
+		}
+	}
+}
+
+func TestEnrichInventoryItemVariant(t *testing.T) {
+	c := loadFixtureCatalog(t)
+
+	got := c.EnrichInventoryItem(inventory.Item{ItemId: 1, Class: "chair_polyfon*4", Type: "S"})
+	if got.Name != "Red Dining Chair" || got.NameSource != TextFromExternalTexts {
+		t.Errorf("name %q from %s", got.Name, got.NameSource)
+	}
+	if got.BaseClass != "chair_polyfon" || got.Variant != "4" {
+		t.Errorf("base %q, variant %q", got.BaseClass, got.Variant)
+	}
+	if len(got.Colors) != 1 || got.Colors[0] != "#ff0000" {
+		t.Errorf("colors %v", got.Colors)
+	}
+}
+
+func TestEnrichRoom(t *testing.T) {
+	c := loadFixtureCatalog(t)
+
+	obj := c.EnrichRoomObject(room.Object{Id: 1, Class: "throne", X: 3, Y: 4, Width: 1, Height: 1})
+	if obj.Name != "Throne" || obj.HCValue != 5000 || !obj.Rare || obj.X != 3 || obj.Y != 4 {
+		t.Errorf("room object %+v", obj)
+	}
+
+	item := c.EnrichRoomItem(room.Item{Id: 2, Class: "poster", Type: "5003", Location: ":w=1,2 l=3,4 r"})
+	if item.Name != "Purple Garland" || item.HCValue != 350 || item.Location != ":w=1,2 l=3,4 r" {
+		t.Errorf("room item %+v", item)
+	}
+}
+
+func TestEnrichUnknownItem(t *testing.T) {
+	c := loadFixtureCatalog(t)
+
+	got := c.EnrichInventoryItem(inventory.Item{ItemId: 1, Class: "no_such_furni", Type: "S"})
+	if got.Name != "no_such_furni" || got.NameSource != TextFromClassName {
+		t.Errorf("name %q from %s", got.Name, got.NameSource)
+	}
+	if got.HCValue != 0 || got.PriceSource != "" || got.IconURL != "" {
+		t.Errorf("value %s from %q, icon %q", got.HCValue, got.PriceSource, got.IconURL)
+	}
+}
@@ -1,12 +1,8 @@
 package common
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"strings"
+	"sync"
+	"sync/atomic"
 
 	"xabbo.b7c.io/goearth/shockwave/inventory"
 	"xabbo.b7c.io/goearth/shockwave/room"
@@ -14,322 +10,262 @@ import (
 
 const (
 	IconBaseURL      = "https://images.habbo.com/dcr/hof_furni/%s/"
-	FurniDataBaseURL = "https://origins.habbo.com/gamedata/furnidata_json/6e9408e1a9015a995c15203f246d8d2d61c5f72d"
+	FurniDataBaseURL = "https://origins.habbo.com/gamedata/furnidata_json/" + furniDataHash
 	APIItemsURL      = "https://tc-api.serversia.com/items"
 )
 
 var (
-	furniData     map[string]FurniData
-	externalTexts map[string]string
-	apiItems      map[string]APIItem
+	sourceForHotel = func(hotel Hotel) GameDataSource { return NewHotelSource(hotel) }
+	defaultCatalog atomic.Pointer[Catalog]
+	loadMu         sync.Mutex
 )
 
+func init() {
+	defaultCatalog.Store(&Catalog{})
+}
+
 type UnifiedInventory struct {
 	Items map[int]EnrichedInventoryItem
 }
 
 type FurniData struct {
-	ID         int    `json:"id"`
-	ClassName  string `json:"classname"`
-	Revision   int    `json:"revision"`
-	Name       string `json:"name"`
-	Rare       bool   `json:"rare"`
-	ExternalID string `json:"externalid"`
+	ID          int        `json:"id"`
+	ClassName   string     `json:"classname"`
+	Revision    int        `json:"revision"`
+	Category    string     `json:"category"`
+	DefaultDir  int        `json:"defaultdir"`
+	XDim        int        `json:"xdim"`
+	YDim        int        `json:"ydim"`
+	PartColors  PartColors `json:"partcolors"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	OfferID     int        `json:"offerid"`
+	Rare        bool       `json:"rare"`
+	ExternalID  string     `json:"externalid"`
+	WallItem    bool       `json:"-"`
+}
+
+// PartColors lists the colours of each part of a furni
+type PartColors struct {
+	Color []string `json:"color"`
 }
 
 type APIItem struct {
-	ID    int     `json:"id"`
-	Name  string  `json:"name"`
-	Slug  string  `json:"slug"`
-	HCVal float64 `json:"hc_val"`
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	HCVal HC     `json:"hc_val"`
 }
 
 type EnrichedInventoryItem struct {
 	inventory.Item
-	Name     string
-	IconURL  string
-	HCValue  float64
-	GroupKey string
+	Name        string
+	NameSource  string
+	Description string
+	IconURL     string
+	HCValue     HC
+	PriceSource string
+	Rare        bool
+	GroupKey    string
+	BaseClass   string
+	Variant     string
+	Category    string
+	XDim        int
+	YDim        int
+	Colors      []string
+}
+
+// PriceKey returns the key the item is priced by
+func (e EnrichedInventoryItem) PriceKey() PriceKey {
+	return PriceKey{
+		ClassName: e.Class,
+		ItemType:  string(e.Type),
+		Props:     e.Props,
+		Name:      e.Name,
+	}
+}
+
+// BaseGroupKey returns the key that groups all colour variants of the item
+// together
+func (e EnrichedInventoryItem) BaseGroupKey() string {
+	if e.Variant == "" {
+		return e.GroupKey
+	}
+	return e.BaseClass
 }
 
 type EnrichedRoomObject struct {
 	room.Object
-	Name      string
-	IconURL   string
-	HCValue   float64
-	Width     int
-	Height    int
-	X         int
-	Y         int
-	Direction int
+	Name        string
+	NameSource  string
+	Description string
+	IconURL     string
+	HCValue     HC
+	PriceSource string
+	Rare        bool
+	BaseClass   string
+	Variant     string
+	Category    string
+	Width       int
+	Height      int
+	X           int
+	Y           int
+	Direction   int
+	Colors      []string
 }
 
 type EnrichedRoomItem struct {
 	room.Item
-	Name     string
-	IconURL  string
-	HCValue  float64
-	Location string
+	Name        string
+	NameSource  string
+	Description string
+	IconURL     string
+	HCValue     HC
+	PriceSource string
+	Rare        bool
+	Category    string
+	Location    string
 }
 
-func GetItemName(class string, itemType string, props string) string {
-	var key string
-	if itemType == "I" {
-		key = fmt.Sprintf("poster_%s_name", props)
-	} else {
-		key = fmt.Sprintf("furni_%s_name", class)
-	}
-
-	name, ok := externalTexts[key]
-	if ok && name != "" {
-		return name
-	}
-
-	if itemType == "I" {
-		return fmt.Sprintf("%s_%s", class, props)
-	} else {
-		return class
-	}
+// DefaultCatalog returns the catalog used by the package level helpers.
+// Catalogs are never modified once they are published, so the result can be
+// used without locking while a reload swaps in a new one.
+func DefaultCatalog() *Catalog {
+	return defaultCatalog.Load()
 }
 
-func GetIconURL(classname string, itemType string, props string) string {
-	classnameForIcon := strings.ReplaceAll(classname, "*", "_")
-
-	var iconURL string
-	if itemType == "I" {
-		revision := "56783"
-		iconURL = fmt.Sprintf("%s%s%s_icon.png", fmt.Sprintf(IconBaseURL, revision), "poster", props)
-	} else {
-		furni, ok := furniData[classname]
-		if !ok {
-			return ""
-		}
-
-		revision := fmt.Sprintf("%d", furni.Revision)
-		iconURL = fmt.Sprintf("%s%s_icon.png", fmt.Sprintf(IconBaseURL, revision), classnameForIcon)
-	}
+// SetDefaultCatalog atomically replaces the catalog used by the package level
+// helpers
+func SetDefaultCatalog(c *Catalog) {
+	defaultCatalog.Store(c)
+}
 
-	return iconURL
+// SetDefaultSource makes every hotel load its data from src, for example a
+// local mirror, instead of the live hotel endpoints
+func SetDefaultSource(src GameDataSource) {
+	sourceForHotel = func(Hotel) GameDataSource { return src }
 }
 
-var specialNameMappings = map[string]string{
-	"Habbo Cola Machine":     "Cola Machine",
-	"Bonnie Blonde's Pillow": "Purple Velvet Pillow",
-	"Imperial Teleport":      "Imperial Teleports",
-	"poster_5003":            "Purple Garland",
-	"poster_5000":            "Green Garland",
-	"Club sofa":              "Club Sofa",
-	"Dicemaster":             "Dice Master",
+func GetItemName(class string, itemType string, props string) string {
+	return DefaultCatalog().GetItemName(class, itemType, props)
 }
 
-func GetHCValue(itemName string) float64 {
-	if mappedName, exists := specialNameMappings[itemName]; exists {
-		itemName = mappedName
-	}
+func GetItemDescription(class string, itemType string, props string) string {
+	return DefaultCatalog().GetItemDescription(class, itemType, props)
+}
 
-	if item, ok := apiItems[itemName]; ok {
-		return item.HCVal
-	}
+func GetIconURL(classname string, itemType string, props string) string {
+	return DefaultCatalog().GetIconURL(classname, itemType, props)
+}
 
-	return 0
+func GetHCValue(itemName string) HC {
+	return DefaultCatalog().GetHCValue(itemName)
 }
 
 func LoadFurniData(gameHost string) error {
-	resp, err := http.Get(FurniDataBaseURL)
+	hotel := HotelForHost(gameHost)
+	data, err := sourceForHotel(hotel).FurniData()
 	if err != nil {
+		updateDefaultCatalog(func(c *Catalog) {
+			c.status.FurniData = NewDataStatus(err).keptPrevious(c.furniData != nil)
+		})
 		return err
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	var data struct {
-		RoomItemTypes struct {
-			FurniType []FurniData `json:"furnitype"`
-		} `json:"roomitemtypes"`
-	}
-
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		return err
-	}
-
-	furniData = make(map[string]FurniData)
-	for _, furni := range data.RoomItemTypes.FurniType {
-		furniData[furni.ClassName] = furni
-	}
+	updateDefaultCatalog(func(c *Catalog) {
+		c.setFurniData(data)
+		c.hotel = hotel
+		c.status.FurniData = NewDataStatus(nil)
+	})
 
 	return nil
 }
 
 func LoadExternalTexts(gameHost string) error {
-	externalTextsURL := fmt.Sprintf("https://origins-gamedata.habbo.com/external_texts/1")
-
-	resp, err := http.Get(externalTextsURL)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	hotel := HotelForHost(gameHost)
+	texts, err := sourceForHotel(hotel).ExternalTexts()
 	if err != nil {
+		updateDefaultCatalog(func(c *Catalog) {
+			c.status.ExternalTexts = NewDataStatus(err).keptPrevious(c.externalTexts != nil)
+		})
 		return err
 	}
-
-	lines := strings.Split(string(body), "\n")
-	externalTexts = make(map[string]string)
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			externalTexts[key] = value
-		}
-	}
+	updateDefaultCatalog(func(c *Catalog) {
+		c.externalTexts = texts
+		c.hotel = hotel
+		c.status.ExternalTexts = NewDataStatus(nil)
+	})
 
 	return nil
 }
 
 func LoadAPIItems() error {
-	resp, err := http.Get(APIItemsURL)
+	items, err := sourceForHotel(DefaultCatalog().hotel).APIItems()
 	if err != nil {
+		updateDefaultCatalog(func(c *Catalog) {
+			c.status.Prices = NewDataStatus(err).keptPrevious(c.apiItems != nil)
+		})
 		return err
 	}
-	defer resp.Body.Close()
+	updateDefaultCatalog(func(c *Catalog) {
+		c.setAPIItems(items)
+		c.status.Prices = NewDataStatus(nil)
+	})
 
-	var items []APIItem
-	err = json.NewDecoder(resp.Body).Decode(&items)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	apiItems = make(map[string]APIItem)
-	for _, item := range items {
-		apiItems[item.Name] = item
-	}
+// updateDefaultCatalog publishes a modified copy of the default catalog
+func updateDefaultCatalog(update func(c *Catalog)) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
 
-	return nil
+	c := DefaultCatalog().clone()
+	update(c)
+	SetDefaultCatalog(c)
 }
 
 func EnrichInventoryItem(item inventory.Item) EnrichedInventoryItem {
-	name := GetItemName(item.Class, string(item.Type), item.Props)
-	groupKey := item.Class
-	if item.Type == "I" {
-		groupKey = fmt.Sprintf("%s_%s", item.Class, item.Props)
-	}
-	return EnrichedInventoryItem{
-		Item:     item,
-		Name:     name,
-		IconURL:  GetIconURL(item.Class, string(item.Type), item.Props),
-		HCValue:  GetHCValue(name),
-		GroupKey: groupKey,
-	}
+	return DefaultCatalog().EnrichInventoryItem(item)
 }
 
 func EnrichRoomObject(obj room.Object) EnrichedRoomObject {
-	return EnrichedRoomObject{
-		Object:    obj,
-		Name:      GetItemName(obj.Class, "S", ""),
-		IconURL:   GetIconURL(obj.Class, "S", ""),
-		HCValue:   GetHCValue(GetItemName(obj.Class, "S", "")),
-		Width:     obj.Width,
-		Height:    obj.Height,
-		X:         obj.X,
-		Y:         obj.Y,
-		Direction: obj.Direction,
-	}
+	return DefaultCatalog().EnrichRoomObject(obj)
 }
 
 func EnrichRoomItem(item room.Item) EnrichedRoomItem {
-	return EnrichedRoomItem{
-		Item:     item,
-		Name:     GetItemName(item.Class, "I", item.Type),
-		IconURL:  GetIconURL(item.Class, "I", item.Type),
-		HCValue:  GetHCValue(GetItemName(item.Class, "I", item.Type)),
-		Location: item.Location,
-	}
+	return DefaultCatalog().EnrichRoomItem(item)
 }
 
 func GetInventorySummary(items map[int]inventory.Item) string {
-	itemCounts := make(map[string]int)
-	totalHC := 0.0
-
-	for _, item := range items {
-		name := GetItemName(item.Class, string(item.Type), item.Props)
-		itemCounts[name]++
-		totalHC += GetHCValue(name)
-	}
-
-	var summary strings.Builder
-	summary.WriteString(fmt.Sprintf("Total unique items: %d\n", len(itemCounts)))
-	summary.WriteString(fmt.Sprintf("Total items: %d\n", len(items)))
-	summary.WriteString(fmt.Sprintf("Total wealth: %.2f HC (values from traderclub.gg)\n", totalHC))
-	summary.WriteString("------------------\n")
-
-	for name, count := range itemCounts {
-		hcValue := GetHCValue(name)
-		summary.WriteString(fmt.Sprintf("%s: %d (%.2f HC)\n", name, count, hcValue))
-	}
-
-	return summary.String()
+	return DefaultCatalog().GetInventorySummary(items)
 }
 
 func GetRoomSummary(objects map[int]room.Object, items map[int]room.Item) string {
-	itemCounts := make(map[string]int)
-	totalHC := 0.0
-
-	for _, obj := range objects {
-		name := GetItemName(obj.Class, "S", "")
-		itemCounts[name]++
-		totalHC += GetHCValue(name)
-	}
-
-	for _, item := range items {
-		name := GetItemName(item.Class, "I", item.Type)
-		itemCounts[name]++
-		totalHC += GetHCValue(name)
-	}
-
-	var summary strings.Builder
-	summary.WriteString(fmt.Sprintf("Total unique items: %d\n", len(itemCounts)))
-	summary.WriteString(fmt.Sprintf("Total items: %d\n", len(objects)+len(items)))
-	summary.WriteString(fmt.Sprintf("Total wealth: %.2f HC (values from traderclub.gg)\n", totalHC))
-	summary.WriteString("------------------\n")
-
-	for name, count := range itemCounts {
-		hcValue := GetHCValue(name)
-		summary.WriteString(fmt.Sprintf("%s: %d (%.2f HC)\n", name, count, hcValue))
-	}
-
-	return summary.String()
+	return DefaultCatalog().GetRoomSummary(objects, items)
 }
 
 func GetInventoryItemDetails(item inventory.Item) string {
-	name := GetItemName(item.Class, string(item.Type), item.Props)
-	return fmt.Sprintf("Name: %s\nID: %d\nType: %s\nClass: %s\nProps: %s\n",
-		name, item.ItemId, item.Type, item.Class, item.Props)
+	return DefaultCatalog().GetInventoryItemDetails(item)
 }
 
 func GetRoomItemDetails(item room.Item) string {
-	name := GetItemName(item.Class, "I", item.Type)
-	return fmt.Sprintf("Name: %s\nID: %d\nClass: %s\nOwner: %s\nLocation: %s\nType: %s\n",
-		name, item.Id, item.Class, item.Owner, item.Location, item.Type)
+	return DefaultCatalog().GetRoomItemDetails(item)
 }
 
 func GetRoomObjectDetails(obj room.Object) string {
-	name := GetItemName(obj.Class, "S", "")
-	return fmt.Sprintf("Name: %s\nID: %d\nClass: %s\nPosition: (%d, %d, %.2f)\nSize: %dx%d\nDirection: %d\n",
-		name, obj.Id, obj.Class, obj.X, obj.Y, obj.Z, obj.Width, obj.Height, obj.Direction)
+	return DefaultCatalog().GetRoomObjectDetails(obj)
 }
 
 // Embed represents a Discord embed message
 type Embed struct {
 	Title       string  `json:"title,omitempty"`
 	Description string  `json:"description,omitempty"`
+	URL         string  `json:"url,omitempty"`
+	Timestamp   string  `json:"timestamp,omitempty"`
 	Color       int     `json:"color,omitempty"`
+	Thumbnail   *Image  `json:"thumbnail,omitempty"`
+	Image       *Image  `json:"image,omitempty"`
+	Footer      *Footer `json:"footer,omitempty"`
 	Fields      []Field `json:"fields,omitempty"`
 }
 
@@ -338,6 +274,11 @@ type Image struct {
 	URL string `json:"url"`
 }
 
+// Footer represents the footer of a Discord embed message
+type Footer struct {
+	Text string `json:"text"`
+}
+
 // Field represents a field in a Discord embed message
 type Field struct {
 	Name   string `json:"name"`
@@ -350,25 +291,8 @@ type WebhookPayload struct {
 	Embeds []Embed `json:"embeds"`
 }
 
-// SendToDiscord sends a message to the Discord webhook URL
+// SendToDiscord sends a message to the Discord webhook URL. Embeds that do
+// not fit in one message are split over several.
 func SendToDiscord(webhookURL string, embeds []Embed) error {
-	payload := WebhookPayload{
-		Embeds: embeds,
-	}
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != 204 {
-		return fmt.Errorf("failed to send message to Discord, status code: %d", resp.StatusCode)
-	}
-
-	return nil
+	return NewDiscordWebhook(webhookURL).Send(embeds)
 }
@@ -0,0 +1,370 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Discord message limits
+const (
+	maxEmbedsPerMessage   = 10
+	maxEmbedFields        = 25
+	maxEmbedChars         = 6000
+	maxEmbedTitle         = 256
+	maxEmbedDescription   = 4096
+	maxEmbedFieldName     = 256
+	maxEmbedFieldValue    = 1024
+	maxRateLimitWait      = time.Minute
+	defaultDiscordRetries = 3
+)
+
+// Embed colours used by the reports
+const (
+	ColorDefault     = 0x3498DB
+	ColorRare        = 0xF1C40F
+	ColorUnavailable = 0xE74C3C
+)
+
+// DiscordWebhook posts messages to a Discord webhook. Rate limited requests
+// are retried after the delay Discord asks for, up to MaxRetries times.
+type DiscordWebhook struct {
+	URL        string
+	Client     *http.Client
+	MaxRetries int
+}
+
+func NewDiscordWebhook(url string) *DiscordWebhook {
+	return &DiscordWebhook{
+		URL:        url,
+		Client:     &http.Client{Timeout: DefaultFetchTimeout},
+		MaxRetries: defaultDiscordRetries,
+	}
+}
+
+// DiscordFile is a file attached to a message. Embeds refer to it as
+// attachment://<Name>.
+type DiscordFile struct {
+	Name string
+	Data []byte
+}
+
+// SendError is returned when one of the messages of a Send fails. The
+// messages before it were posted already; pass Sent to SendFrom to post the
+// rest without repeating them.
+type SendError struct {
+	Sent     int
+	Messages int
+	Err      error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("discord: sent %d of %d messages: %v", e.Sent, e.Messages, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Send posts embeds, split over as many messages as the limits require.
+// Files are attached to the first message.
+func (d *DiscordWebhook) Send(embeds []Embed, files ...DiscordFile) error {
+	return d.SendFrom(0, embeds, files...)
+}
+
+// SendFrom posts the messages of embeds from message start on, resuming a
+// Send that failed with a SendError
+func (d *DiscordWebhook) SendFrom(start int, embeds []Embed, files ...DiscordFile) error {
+	messages := SplitEmbeds(embeds)
+	for i := start; i < len(messages); i++ {
+		var attached []DiscordFile
+		if i == 0 {
+			attached = files
+		}
+		if err := d.post(WebhookPayload{Embeds: messages[i]}, attached); err != nil {
+			return &SendError{Sent: i, Messages: len(messages), Err: err}
+		}
+	}
+	return nil
+}
+
+func (d *DiscordWebhook) post(payload WebhookPayload, files []DiscordFile) error {
+	body, contentType, err := encodeWebhookPayload(payload, files)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := d.Client.Post(d.URL, contentType, bytes.NewReader(body))
+		if err != nil {
+			return &NetworkError{URL: "discord webhook", Err: err}
+		}
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= d.MaxRetries {
+			return &HTTPStatusError{URL: "discord webhook", StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+
+		wait := retryAfter(resp, respBody)
+		if wait > maxRateLimitWait {
+			return &HTTPStatusError{URL: "discord webhook", StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		time.Sleep(wait)
+	}
+}
+
+// encodeWebhookPayload encodes payload as JSON, or as multipart form data
+// when files are attached
+func encodeWebhookPayload(payload WebhookPayload, files []DiscordFile) ([]byte, string, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(files) == 0 {
+		return payloadBytes, "application/json", nil
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("payload_json", string(payloadBytes)); err != nil {
+		return nil, "", err
+	}
+	for i, file := range files {
+		part, err := writer.CreateFormFile(fmt.Sprintf("files[%d]", i), file.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// retryAfter reads the delay of a 429 response from the Retry-After header
+// or the retry_after field of the body, both in seconds
+func retryAfter(resp *http.Response, body []byte) time.Duration {
+	var rateLimit struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &rateLimit) == nil && rateLimit.RetryAfter > 0 {
+		return time.Duration(rateLimit.RetryAfter * float64(time.Second))
+	}
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return time.Second
+}
+
+// SplitEmbeds truncates overlong texts, continues embeds with more than 25
+// fields or 6000 characters in follow-up embeds and groups the result into
+// messages that stay within Discord's limits
+func SplitEmbeds(embeds []Embed) [][]Embed {
+	var split []Embed
+	for _, embed := range embeds {
+		split = append(split, splitEmbed(embed)...)
+	}
+
+	var messages [][]Embed
+	var current []Embed
+	chars := 0
+	for _, embed := range split {
+		length := embedLength(embed)
+		if len(current) > 0 && (len(current) == maxEmbedsPerMessage || chars+length > maxEmbedChars) {
+			messages = append(messages, current)
+			current, chars = nil, 0
+		}
+		current = append(current, embed)
+		chars += length
+	}
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+func splitEmbed(embed Embed) []Embed {
+	embed.Title = truncate(embed.Title, maxEmbedTitle)
+	embed.Description = truncate(embed.Description, maxEmbedDescription)
+	fields := embed.Fields
+	embed.Fields = nil
+
+	result := []Embed{embed}
+	current := &result[0]
+	for _, field := range fields {
+		field.Name = truncate(field.Name, maxEmbedFieldName)
+		field.Value = truncate(field.Value, maxEmbedFieldValue)
+
+		fieldLength := utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+		if len(current.Fields) == maxEmbedFields || embedLength(*current)+fieldLength > maxEmbedChars {
+			result = append(result, Embed{
+				Title:     truncate(embed.Title+" (continued)", maxEmbedTitle),
+				Color:     embed.Color,
+				Timestamp: embed.Timestamp,
+			})
+			current = &result[len(result)-1]
+		}
+		current.Fields = append(current.Fields, field)
+	}
+	return result
+}
+
+// embedLength counts the characters Discord counts towards the 6000 limit
+func embedLength(embed Embed) int {
+	n := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	if embed.Footer != nil {
+		n += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	for _, field := range embed.Fields {
+		n += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	return n
+}
+
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:limit-1]) + "…"
+}
+
+// SummaryEmbeds turns an inventory or room summary into Discord embeds. The
+// most valuable item is the thumbnail, rares are starred and make the embed
+// gold.
+func SummaryEmbeds(s Summary) []Embed {
+	var description strings.Builder
+	if s.Hotel != "" {
+		description.WriteString(s.Hotel + "\n")
+	}
+	description.WriteString(fmt.Sprintf("**%d** items, **%d** unique\n", s.TotalItems, s.TotalUniqueItems))
+	description.WriteString(fmt.Sprintf("Total value: **%s HC** (values from %s)", s.TotalValue, s.sourceList()))
+	if !s.PriceData.Available {
+		description.WriteString("\nPrice data unavailable: " + s.PriceData.Message)
+	}
+	if s.Rarity.RareItems > 0 {
+		description.WriteString("\n★ " + s.rarityText())
+	}
+	if len(s.Rarity.UnpricedRares) > 0 {
+		description.WriteString("\n**Unpriced rares:** " + strings.Join(s.Rarity.UnpricedRares, ", "))
+	}
+	if s.Omitted > 0 {
+		description.WriteString(fmt.Sprintf("\n%d more items not listed", s.Omitted))
+	}
+
+	embed := Embed{
+		Title:       s.Title + " summary",
+		Description: description.String(),
+		Timestamp:   s.GeneratedAt.Format(time.RFC3339),
+		Color:       ColorDefault,
+	}
+	if !s.PriceData.Available {
+		embed.Color = ColorUnavailable
+	}
+
+	for _, line := range s.Lines {
+		if embed.Thumbnail == nil && line.IconURL != "" {
+			embed.Thumbnail = &Image{URL: line.IconURL}
+		}
+		if line.Rare && embed.Color == ColorDefault {
+			embed.Color = ColorRare
+		}
+		embed.Fields = append(embed.Fields, Field{
+			Name:   rareName(line.Name, line.Rare),
+			Value:  summaryLineValue(line),
+			Inline: true,
+		})
+	}
+	return []Embed{embed}
+}
+
+func summaryLineValue(line SummaryLine) string {
+	value := fmt.Sprintf("%d × %s HC = %s HC", line.Quantity, line.UnitValue, line.TotalValue)
+	if line.PriceSource != "" {
+		value += " (" + line.PriceSource + ")"
+	}
+	return value
+}
+
+// TradeEmbeds describes both sides of a trade, one embed per side
+func TradeEmbeds(trader string, traderValuation Valuation, tradee string, tradeeValuation Valuation) []Embed {
+	difference := traderValuation.Total - tradeeValuation.Total
+	sign := ""
+	if difference > 0 {
+		sign = "+"
+	}
+	return []Embed{
+		tradeSideEmbed(trader, traderValuation, fmt.Sprintf("Difference: %s%s HC", sign, difference)),
+		tradeSideEmbed(tradee, tradeeValuation, ""),
+	}
+}
+
+func tradeSideEmbed(name string, valuation Valuation, extra string) Embed {
+	description := fmt.Sprintf("Total value: **%s HC**", valuation.Total)
+	if len(valuation.Sources) > 0 {
+		description += " (values from " + strings.Join(valuation.Sources, ", ") + ")"
+	}
+	if extra != "" {
+		description += "\n" + extra
+	}
+
+	embed := Embed{
+		Title:       name + " offers",
+		Description: description,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Color:       ColorDefault,
+	}
+
+	type group struct {
+		item     ValuedItem
+		quantity int
+		total    HC
+	}
+	var order []string
+	groups := make(map[string]*group)
+	for _, item := range valuation.Items {
+		g, ok := groups[item.Name]
+		if !ok {
+			g = &group{item: item}
+			groups[item.Name] = g
+			order = append(order, item.Name)
+		}
+		g.quantity++
+		g.total += item.HCValue
+	}
+
+	for _, name := range order {
+		g := groups[name]
+		if embed.Thumbnail == nil && g.item.IconURL != "" {
+			embed.Thumbnail = &Image{URL: g.item.IconURL}
+		}
+		if g.item.Rare {
+			embed.Color = ColorRare
+		}
+		embed.Fields = append(embed.Fields, Field{
+			Name: rareName(name, g.item.Rare),
+			Value: summaryLineValue(SummaryLine{
+				Quantity:    g.quantity,
+				UnitValue:   g.total.Div(g.quantity),
+				TotalValue:  g.total,
+				PriceSource: g.item.PriceSource,
+			}),
+			Inline: true,
+		})
+	}
+	return embed
+}
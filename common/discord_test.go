@@ -0,0 +1,263 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// webhookServer records the messages posted to it. fail, when set, picks the
+// status to answer a request with; 0 accepts the message and -1 means fail
+// wrote the response itself.
+type webhookServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	calls     int
+	messages  []WebhookPayload
+	multipart []bool
+	fail      func(call int, w http.ResponseWriter) int
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	t.Helper()
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.calls++
+		if s.fail != nil {
+			if status := s.fail(s.calls, w); status > 0 {
+				w.WriteHeader(status)
+				return
+			} else if status < 0 {
+				return
+			}
+		}
+
+		var payload WebhookPayload
+		isMultipart := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/")
+		if isMultipart {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("multipart: %v", err)
+			}
+			if _, _, err := r.FormFile("files[0]"); err != nil {
+				t.Errorf("files[0]: %v", err)
+			}
+			json.Unmarshal([]byte(r.FormValue("payload_json")), &payload)
+		} else {
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &payload)
+		}
+		s.messages = append(s.messages, payload)
+		s.multipart = append(s.multipart, isMultipart)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// manyFields is an embed that needs several messages
+func manyFields(n int) Embed {
+	embed := Embed{Title: "Inventory"}
+	for i := 0; i < n; i++ {
+		embed.Fields = append(embed.Fields, Field{
+			Name:  fmt.Sprintf("Item %d", i),
+			Value: strings.Repeat("x", 200),
+		})
+	}
+	return embed
+}
+
+func fieldNames(messages [][]Embed) []string {
+	var names []string
+	for _, message := range messages {
+		for _, embed := range message {
+			for _, field := range embed.Fields {
+				names = append(names, field.Name)
+			}
+		}
+	}
+	return names
+}
+
+func TestSplitEmbedsLimits(t *testing.T) {
+	embeds := []Embed{manyFields(120), {Title: strings.Repeat("t", 300), Description: strings.Repeat("d", 5000)}}
+	for i := 0; i < 15; i++ {
+		embeds = append(embeds, Embed{Title: fmt.Sprintf("Small %d", i)})
+	}
+
+	messages := SplitEmbeds(embeds)
+	for i, message := range messages {
+		if len(message) > maxEmbedsPerMessage {
+			t.Errorf("message %d has %d embeds", i, len(message))
+		}
+		chars := 0
+		for _, embed := range message {
+			if len(embed.Fields) > maxEmbedFields {
+				t.Errorf("message %d has an embed with %d fields", i, len(embed.Fields))
+			}
+			chars += embedLength(embed)
+		}
+		if chars > maxEmbedChars {
+			t.Errorf("message %d has %d characters", i, chars)
+		}
+	}
+
+	names := fieldNames(messages)
+	if len(names) != 120 || names[0] != "Item 0" || names[119] != "Item 119" {
+		t.Errorf("fields were lost or reordered: %d fields", len(names))
+	}
+
+	var long Embed
+	for _, message := range messages {
+		for _, embed := range message {
+			if strings.HasPrefix(embed.Title, "ttt") {
+				long = embed
+			}
+		}
+	}
+	if len([]rune(long.Title)) != maxEmbedTitle || !strings.HasSuffix(long.Title, "…") {
+		t.Errorf("title not truncated to %d: %d", maxEmbedTitle, len([]rune(long.Title)))
+	}
+}
+
+func TestSplitEmbedsSmall(t *testing.T) {
+	messages := SplitEmbeds([]Embed{{Title: "a"}, {Title: "b"}})
+	if len(messages) != 1 || len(messages[0]) != 2 {
+		t.Errorf("want one message with both embeds, got %v", messages)
+	}
+	if len(SplitEmbeds(nil)) != 0 {
+		t.Error("no embeds should give no messages")
+	}
+}
+
+func TestDiscordWebhookRetryAfter(t *testing.T) {
+	srv := newWebhookServer(t)
+	srv.fail = func(call int, w http.ResponseWriter) int {
+		switch call {
+		case 1:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message":"You are being rate limited.","retry_after":0.01}`)
+			return -1
+		case 2:
+			w.Header().Set("Retry-After", "0.01")
+			return http.StatusTooManyRequests
+		}
+		return 0
+	}
+
+	if err := NewDiscordWebhook(srv.URL).Send([]Embed{{Title: "hello"}}); err != nil {
+		t.Fatal(err)
+	}
+	if srv.calls != 3 || len(srv.messages) != 1 {
+		t.Errorf("%d calls, %d messages", srv.calls, len(srv.messages))
+	}
+}
+
+func TestDiscordWebhookRateLimitGivesUp(t *testing.T) {
+	tests := map[string]func(call int, w http.ResponseWriter) int{
+		"too many retries": func(call int, w http.ResponseWriter) int {
+			w.Header().Set("Retry-After", "0.001")
+			return http.StatusTooManyRequests
+		},
+		"wait too long": func(call int, w http.ResponseWriter) int {
+			w.Header().Set("Retry-After", "3600")
+			return http.StatusTooManyRequests
+		},
+	}
+	for name, fail := range tests {
+		srv := newWebhookServer(t)
+		srv.fail = fail
+
+		err := NewDiscordWebhook(srv.URL).Send([]Embed{{Title: "hello"}})
+		var statusErr *HTTPStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("%s: want HTTPStatusError 429, got %v", name, err)
+		}
+	}
+}
+
+func TestDiscordWebhookAttachesFilesToFirstMessage(t *testing.T) {
+	srv := newWebhookServer(t)
+
+	err := NewDiscordWebhook(srv.URL).Send([]Embed{manyFields(60)}, DiscordFile{Name: "room.png", Data: []byte("png")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.messages) < 2 {
+		t.Fatalf("want several messages, got %d", len(srv.messages))
+	}
+	for i, isMultipart := range srv.multipart {
+		if isMultipart != (i == 0) {
+			t.Errorf("message %d multipart %v", i, isMultipart)
+		}
+	}
+}
+
+func TestDiscordWebhookSendFrom(t *testing.T) {
+	srv := newWebhookServer(t)
+	srv.fail = func(call int, w http.ResponseWriter) int {
+		if call == 2 {
+			return http.StatusInternalServerError
+		}
+		return 0
+	}
+	webhook := NewDiscordWebhook(srv.URL)
+	embeds := []Embed{manyFields(60)}
+	messages := SplitEmbeds(embeds)
+
+	err := webhook.Send(embeds)
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || sendErr.Sent != 1 || sendErr.Messages != len(messages) {
+		t.Fatalf("want SendError after 1 message, got %v", err)
+	}
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("SendError does not wrap the status: %v", err)
+	}
+
+	if err := webhook.SendFrom(sendErr.Sent, embeds); err != nil {
+		t.Fatal(err)
+	}
+	var posted [][]Embed
+	for _, message := range srv.messages {
+		posted = append(posted, message.Embeds)
+	}
+	if got, want := fieldNames(posted), fieldNames(messages); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("posted %d fields, want each of the %d once", len(got), len(want))
+	}
+}
+
+func TestDiscordNotifierResumes(t *testing.T) {
+	srv := newWebhookServer(t)
+	srv.fail = func(call int, w http.ResponseWriter) int {
+		if call == 2 {
+			return http.StatusBadGateway
+		}
+		return 0
+	}
+	notifier := &DiscordNotifier{SinkName: "discord", Webhook: NewDiscordWebhook(srv.URL)}
+	n := Notification{ID: "1", Kind: NotifyScanCompleted, Title: "Scan", Fields: manyFields(60).Fields}
+
+	if err := notifier.Notify(n); err == nil {
+		t.Fatal("want an error for the failed message")
+	}
+	if err := notifier.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+
+	var posted [][]Embed
+	for _, message := range srv.messages {
+		posted = append(posted, message.Embeds)
+	}
+	if got := fieldNames(posted); len(got) != 60 {
+		t.Errorf("retry reposted messages: %d fields posted", len(got))
+	}
+}
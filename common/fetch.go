@@ -0,0 +1,211 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultFetchTimeout = 30 * time.Second
+	DefaultFetchRetries = 3
+	DefaultFetchBackoff = 500 * time.Millisecond
+	DefaultMaxBodySize  = 64 << 20
+)
+
+// ErrResponseTooLarge is wrapped in a ParseError when a body exceeds the
+// fetcher's size limit
+var ErrResponseTooLarge = errors.New("response too large")
+
+// NetworkError is returned when a request could not be completed
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("fetching %s: %v", e.URL, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError is returned when the server answers with an unexpected
+// status code
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("fetching %s: unexpected status %s", e.URL, e.Status)
+}
+
+// ParseError is returned when a downloaded or cached body cannot be used
+type ParseError struct {
+	URL string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing %s: %v", e.URL, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Fetcher downloads URLs with a timeout, a size limit and retries. Network
+// errors, 5xx and 429 responses are retried with exponential backoff, any
+// other failure is returned immediately.
+type Fetcher struct {
+	Client      *http.Client
+	Retries     int
+	Backoff     time.Duration
+	MaxBodySize int64
+}
+
+// DefaultFetcher is used by caches that have no fetcher of their own
+var DefaultFetcher = NewFetcher()
+
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		Client:      &http.Client{Timeout: DefaultFetchTimeout},
+		Retries:     DefaultFetchRetries,
+		Backoff:     DefaultFetchBackoff,
+		MaxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// Do sends req, retrying it when the failure is temporary. The body of a
+// successful response is read in full. A 304 response returns the response
+// with a nil body.
+func (f *Fetcher) Do(req *http.Request) (*http.Response, []byte, error) {
+	backoff := f.Backoff
+	var lastErr error
+	for attempt := 0; attempt <= f.Retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("fetch: retrying %s in %v: %v", req.URL, backoff, lastErr)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		resp, body, err := f.do(req)
+		if err == nil {
+			return resp, body, nil
+		}
+		lastErr = err
+		if !retryable(err) {
+			break
+		}
+	}
+	return nil, nil, lastErr
+}
+
+// Get fetches url and returns its body
+func (f *Fetcher) Get(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, body, err := f.Do(req)
+	return body, err
+}
+
+func (f *Fetcher) do(req *http.Request) (*http.Response, []byte, error) {
+	url := req.URL.String()
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, nil, &NetworkError{URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	limit := f.MaxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, nil, &NetworkError{URL: url, Err: err}
+	}
+	if int64(len(body)) > limit {
+		return nil, nil, &ParseError{URL: url, Err: ErrResponseTooLarge}
+	}
+	return resp, body, nil
+}
+
+func retryable(err error) bool {
+	var netErr *NetworkError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// Data status kinds reported by DataStatus.Kind
+const (
+	DataErrorNetwork = "network"
+	DataErrorHTTP    = "http"
+	DataErrorParse   = "parse"
+	DataErrorOther   = "other"
+)
+
+// DataStatus tells the frontend whether a data set is usable and, if not,
+// what kind of failure made it unavailable. A Stale data set failed to
+// reload but still has the contents of an earlier load.
+type DataStatus struct {
+	Available bool
+	Stale     bool
+	Kind      string
+	Message   string
+}
+
+// NewDataStatus describes the result of loading a data set
+func NewDataStatus(err error) DataStatus {
+	if err == nil {
+		return DataStatus{Available: true}
+	}
+	return DataStatus{Kind: ErrorKind(err), Message: err.Error()}
+}
+
+// keptPrevious marks a data set that failed to reload as still available
+// when the contents of an earlier load are kept
+func (s DataStatus) keptPrevious(kept bool) DataStatus {
+	s.Available = kept
+	s.Stale = kept
+	return s
+}
+
+// ErrorKind classifies err as one of the DataError kinds
+func ErrorKind(err error) string {
+	var netErr *NetworkError
+	var statusErr *HTTPStatusError
+	var parseErr *ParseError
+	switch {
+	case errors.As(err, &statusErr):
+		return DataErrorHTTP
+	case errors.As(err, &parseErr):
+		return DataErrorParse
+	case errors.As(err, &netErr):
+		return DataErrorNetwork
+	}
+	return DataErrorOther
+}
@@ -0,0 +1,209 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestFetcher() *Fetcher {
+	f := NewFetcher()
+	f.Backoff = time.Millisecond
+	return f
+}
+
+// flakyServer answers with status for the first failures requests and with
+// body afterwards
+func flakyServer(t *testing.T, failures int32, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestFetcherRetriesTemporaryFailures(t *testing.T) {
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		srv, calls := flakyServer(t, 2, status, "a=b")
+
+		body, err := newTestFetcher().Get(srv.URL)
+		if err != nil {
+			t.Fatalf("status %d: %v", status, err)
+		}
+		if string(body) != "a=b" || calls.Load() != 3 {
+			t.Errorf("status %d: body %q after %d calls", status, body, calls.Load())
+		}
+	}
+}
+
+func TestFetcherGivesUpAfterRetries(t *testing.T) {
+	srv, calls := flakyServer(t, 100, http.StatusBadGateway, "")
+
+	f := newTestFetcher()
+	f.Retries = 2
+	_, err := f.Get(srv.URL)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("want HTTPStatusError 502, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("want 3 calls, got %d", calls.Load())
+	}
+	if ErrorKind(err) != DataErrorHTTP {
+		t.Errorf("kind %q", ErrorKind(err))
+	}
+}
+
+func TestFetcherDoesNotRetryClientErrors(t *testing.T) {
+	srv, calls := flakyServer(t, 100, http.StatusNotFound, "")
+
+	_, err := newTestFetcher().Get(srv.URL)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("want HTTPStatusError 404, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("404 was retried, %d calls", calls.Load())
+	}
+}
+
+func TestFetcherNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	f := newTestFetcher()
+	f.Retries = 1
+	_, err := f.Get(url)
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || netErr.URL != url {
+		t.Fatalf("want NetworkError for %s, got %v", url, err)
+	}
+	if ErrorKind(err) != DataErrorNetwork {
+		t.Errorf("kind %q", ErrorKind(err))
+	}
+}
+
+func TestFetcherBodyLimit(t *testing.T) {
+	srv, calls := flakyServer(t, 0, 0, "0123456789")
+
+	f := newTestFetcher()
+	f.MaxBodySize = 5
+	_, err := f.Get(srv.URL)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("want ParseError wrapping ErrResponseTooLarge, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("oversized body was retried, %d calls", calls.Load())
+	}
+	if ErrorKind(err) != DataErrorParse {
+		t.Errorf("kind %q", ErrorKind(err))
+	}
+}
+
+func TestCacheKeepsLastGoodCopy(t *testing.T) {
+	var page atomic.Value
+	page.Store("furni_a_name=A")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, page.Load())
+	}))
+	defer srv.Close()
+
+	cache := NewCache(t.TempDir())
+	cache.Fetcher = newTestFetcher()
+	if _, err := cache.Get("texts", srv.URL, validateExternalTexts); err != nil {
+		t.Fatal(err)
+	}
+
+	page.Store("<!DOCTYPE html><html><body>Maintenance</body></html>")
+	body, err := cache.Refresh("texts", srv.URL, validateExternalTexts)
+	if err != nil || string(body) != "furni_a_name=A" {
+		t.Fatalf("want the last good copy, got %q, %v", body, err)
+	}
+
+	empty := NewCache(t.TempDir())
+	empty.Fetcher = newTestFetcher()
+	_, err = empty.Get("texts", srv.URL, validateExternalTexts)
+	if ErrorKind(err) != DataErrorParse {
+		t.Fatalf("want a parse error for an HTML page, got %v", err)
+	}
+}
+
+const furniDataV1 = `{"roomitemtypes":{"furnitype":[
+	{"classname":"throne","revision":1,"name":"Throne"},
+	{"classname":"chair","revision":1,"name":"Chair"}]}}`
+
+const furniDataV2 = `{"roomitemtypes":{"furnitype":[
+	{"classname":"throne","revision":2,"name":"Throne"},
+	{"classname":"sofa","revision":1,"name":"Sofa"}]}}`
+
+func TestHTTPSourceDiscoversFurniData(t *testing.T) {
+	var hash atomic.Value
+	hash.Store("v1")
+	mux := http.NewServeMux()
+	srvURL := ""
+	mux.HandleFunc("/variables", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "site.url=http://example.com\nfurnidata.load.url=%s/furnidata/%s\n", srvURL, hash.Load())
+	})
+	mux.HandleFunc("/furnidata/v1", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, furniDataV1) })
+	mux.HandleFunc("/furnidata/v2", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, furniDataV2) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	srvURL = srv.URL
+
+	var changes []FurniDataChange
+	FurniDataChanged(func(change FurniDataChange) {
+		if change.Hotel == "fixture" {
+			changes = append(changes, change)
+		}
+	})
+
+	cache := NewCache(t.TempDir())
+	cache.Fetcher = newTestFetcher()
+	src := &HTTPSource{
+		Hotel:        "fixture",
+		VariablesURL: srv.URL + "/variables",
+		FurniDataURL: srv.URL + "/furnidata/pinned",
+		CachePrefix:  "fixture",
+		Cache:        cache,
+		Revalidate:   true,
+	}
+
+	data, err := src.FurniData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data["chair"]; !ok || len(changes) != 0 {
+		t.Fatalf("first load: %v, %d changes", data, len(changes))
+	}
+
+	hash.Store("v2")
+	data, err = src.FurniData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data["sofa"]; !ok {
+		t.Fatalf("second load did not follow the new location: %v", data)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("want 1 change, got %d", len(changes))
+	}
+	change := changes[0]
+	if change.OldHash != "v1" || change.NewHash != "v2" {
+		t.Errorf("hashes %q -> %q", change.OldHash, change.NewHash)
+	}
+	if fmt.Sprint(change.Added, change.Changed, change.Removed) != "[sofa] [throne] [chair]" {
+		t.Errorf("added %v, changed %v, removed %v", change.Added, change.Changed, change.Removed)
+	}
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// furniDataVariableKeys are the variables that may hold the furnidata
+// location, in order of preference
+var furniDataVariableKeys = []string{
+	"furnidata.load.url",
+	"furnidata.url",
+	"furnidata.json.url",
+}
+
+// FurniDataChange reports what changed when a hotel published a new
+// furnidata version
+type FurniDataChange struct {
+	Hotel      string
+	OldURL     string
+	NewURL     string
+	OldHash    string
+	NewHash    string
+	Added      []string
+	Changed    []string
+	Removed    []string
+	DetectedAt time.Time
+}
+
+var (
+	furniDataChangedHandlers []func(FurniDataChange)
+	furniDataChangedMu       sync.Mutex
+)
+
+// FurniDataChanged registers a handler that is called whenever a loader
+// notices the furnidata version of a hotel changed
+func FurniDataChanged(handler func(FurniDataChange)) {
+	furniDataChangedMu.Lock()
+	defer furniDataChangedMu.Unlock()
+	furniDataChangedHandlers = append(furniDataChangedHandlers, handler)
+}
+
+func dispatchFurniDataChanged(change FurniDataChange) {
+	furniDataChangedMu.Lock()
+	handlers := append([]func(FurniDataChange){}, furniDataChangedHandlers...)
+	furniDataChangedMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(change)
+	}
+}
+
+// FurniDataHash returns the version hash at the end of a furnidata URL
+func FurniDataHash(url string) string {
+	return path.Base(strings.TrimRight(url, "/"))
+}
+
+// resolveFurniDataURL looks up the current furnidata location in the
+// variables index at variablesURL
+func resolveFurniDataURL(s *HTTPSource, variablesURL string) (string, error) {
+	body, err := s.get(s.cacheName("variables"), variablesURL, validateExternalTexts)
+	if err != nil {
+		return "", err
+	}
+
+	variables := parseExternalTexts(body)
+	for _, key := range furniDataVariableKeys {
+		if url := strings.TrimSpace(variables[key]); url != "" {
+			return url, nil
+		}
+	}
+
+	return "", fmt.Errorf("no furnidata location in %s", variablesURL)
+}
+
+// DiffFurniData lists the classnames that were added, changed or removed
+// between two furnidata versions. An entry counts as changed when its
+// revision or name differs.
+func DiffFurniData(old, new map[string]FurniData) (added, changed, removed []string) {
+	for class, furni := range new {
+		prev, ok := old[class]
+		if !ok {
+			added = append(added, class)
+		} else if prev.Revision != furni.Revision || prev.Name != furni.Name {
+			changed = append(changed, class)
+		}
+	}
+	for class := range old {
+		if _, ok := new[class]; !ok {
+			removed = append(removed, class)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// HC is an amount of HC in hundredths. Values are added and subtracted
+// exactly, so running totals never drift the way float64 sums do. HC
+// marshals to and from a plain JSON number such as 12.5.
+type HC int64
+
+// HCFromFloat converts a float64 amount such as a price from an API to HC,
+// rounding half away from zero
+func HCFromFloat(f float64) HC {
+	return HC(math.Round(f * 100))
+}
+
+// ParseHC parses a decimal amount such as "12.5" or "-3.25" exactly. More
+// than two decimals are rounded half away from zero.
+func ParseHC(s string) (HC, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("invalid HC amount %q", s)
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid HC amount %q", s)
+		}
+		return HCFromFloat(f), nil
+	}
+
+	digits := s
+	negative := false
+	if digits[0] == '-' || digits[0] == '+' {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid HC amount %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid HC amount %q", s)
+		}
+	}
+
+	roundUp := len(fraction) > 2 && fraction[2] >= '5'
+	fraction = (fraction + "00")[:2]
+	n, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid HC amount %q: %w", s, err)
+	}
+	if roundUp {
+		if n == math.MaxInt64 {
+			return 0, fmt.Errorf("invalid HC amount %q: value out of range", s)
+		}
+		n++
+	}
+	if negative {
+		n = -n
+	}
+	return HC(n), nil
+}
+
+// Float64 returns the amount as a float64, for display and statistics only
+func (h HC) Float64() float64 {
+	return float64(h) / 100
+}
+
+// Mul returns the amount multiplied by n
+func (h HC) Mul(n int) HC {
+	return h * HC(n)
+}
+
+// Div returns the amount divided by n, rounded half away from zero
+func (h HC) Div(n int) HC {
+	if n == 0 {
+		return 0
+	}
+	q, r := h/HC(n), h%HC(n)
+	if r < 0 {
+		r = -r
+	}
+	if 2*int64(r) >= int64(max(n, -n)) {
+		if (h < 0) != (n < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// String formats the amount with two decimals, without a sign for zero
+func (h HC) String() string {
+	sign := ""
+	n := int64(h)
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/100, n%100)
+}
+
+func (h HC) MarshalJSON() ([]byte, error) {
+	s := h.String()
+	s = strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		s = "0"
+	}
+	return []byte(s), nil
+}
+
+func (h *HC) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	value, err := ParseHC(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*h = value
+	return nil
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseHC(t *testing.T) {
+	tests := []struct {
+		in   string
+		want HC
+	}{
+		{"0", 0},
+		{"12", 1200},
+		{"12.5", 1250},
+		{" 12.50 ", 1250},
+		{".5", 50},
+		{"5.", 500},
+		{"+3.25", 325},
+		{"-3.25", -325},
+		{"-0", 0},
+		// More than two decimals round half away from zero at .005
+		{"0.004", 0},
+		{"0.005", 1},
+		{"0.0049999", 0},
+		{"1.995", 200},
+		{"-0.005", -1},
+		{"-0.004", 0},
+		{"-1.995", -200},
+		{"2.675", 268},
+		{"1e2", 10000},
+		{"1.5E-2", 2},
+		{"92233720368547758.07", math.MaxInt64},
+		{"-92233720368547758.07", -math.MaxInt64},
+	}
+	for _, tt := range tests {
+		if got, err := ParseHC(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseHC(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", " ", "-", "+", ".", "-.", "+-5", "-+5", "--5", "++5", "5-", "1.2.3", "1,5", "abc", "1e", "0x10",
+		"92233720368547758.08", "92233720368547758.075"} {
+		if got, err := ParseHC(in); err == nil {
+			t.Errorf("ParseHC(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestHCDiv(t *testing.T) {
+	tests := []struct {
+		h    HC
+		n    int
+		want HC
+	}{
+		{1000, 4, 250},
+		{1000, 3, 333},
+		{2000, 3, 667},
+		// A remainder of exactly half rounds away from zero
+		{5, 2, 3},
+		{-5, 2, -3},
+		{5, -2, -3},
+		{-5, -2, 3},
+		{7, 4, 2},
+		{-7, 4, -2},
+		{-2000, 3, -667},
+		{1, 3, 0},
+		{-1, 3, 0},
+		{0, 7, 0},
+		{1000, 0, 0},
+		{math.MaxInt64, 1, math.MaxInt64},
+		{math.MaxInt64, 2, math.MaxInt64/2 + 1},
+	}
+	for _, tt := range tests {
+		if got := tt.h.Div(tt.n); got != tt.want {
+			t.Errorf("HC(%d).Div(%d) = %d, want %d", tt.h, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestHCString(t *testing.T) {
+	tests := []struct {
+		h    HC
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{1250, "12.50"},
+		{-1250, "-12.50"},
+		{math.MaxInt64, "92233720368547758.07"},
+	}
+	for _, tt := range tests {
+		if got := tt.h.String(); got != tt.want {
+			t.Errorf("HC(%d).String() = %q, want %q", tt.h, got, tt.want)
+		}
+	}
+}
+
+func TestHCJSON(t *testing.T) {
+	tests := []struct {
+		h    HC
+		want string
+	}{
+		{0, "0"},
+		{5, "0.05"},
+		{50, "0.5"},
+		{-50, "-0.5"},
+		{1000, "10"},
+		{10000, "100"},
+		{1250, "12.5"},
+		{-1, "-0.01"},
+		{math.MaxInt64, "92233720368547758.07"},
+		{-math.MaxInt64, "-92233720368547758.07"},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.h)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("marshal %d: %s, %v, want %s", tt.h, data, err, tt.want)
+			continue
+		}
+		var back HC
+		if err := json.Unmarshal(data, &back); err != nil || back != tt.h {
+			t.Errorf("unmarshal %s: %d, %v, want %d", data, back, err, tt.h)
+		}
+	}
+
+	// Numbers from other sources, quoted amounts and null
+	var decoded struct {
+		A, B, C, D HC
+	}
+	decoded.D = 7
+	if err := json.Unmarshal([]byte(`{"A": 3.14159, "B": "12.5", "C": 1e3, "D": null}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.A != 314 || decoded.B != 1250 || decoded.C != 100000 || decoded.D != 7 {
+		t.Errorf("decoded %+v", decoded)
+	}
+	if err := json.Unmarshal([]byte(`"+-5"`), &decoded.A); err == nil {
+		t.Error("unmarshalled +-5")
+	}
+}
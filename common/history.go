@@ -0,0 +1,259 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const historyDateFormat = "2006-01-02"
+
+// PriceHistoryStore keeps one snapshot of the traderclub price list per day
+var PriceHistoryStore = NewPriceHistory(filepath.Join(defaultCacheDir(), "prices"))
+
+// PricePoint is the value of an item on one day
+type PricePoint struct {
+	Date  time.Time
+	Value HC
+}
+
+// PriceTrend is the percentage change of an item's value. A nil change means
+// there is not enough history to compute it.
+type PriceTrend struct {
+	Change7d  *float64
+	Change30d *float64
+}
+
+// PriceMove is the change of one item's value over a period
+type PriceMove struct {
+	Key    string
+	Name   string
+	From   HC
+	To     HC
+	Change float64
+}
+
+// PriceHistory stores dated price list snapshots in Dir, one JSON file per
+// day named after the date
+type PriceHistory struct {
+	Dir string
+
+	mu        sync.Mutex
+	loaded    bool
+	snapshots map[string]map[string]APIItem
+}
+
+func NewPriceHistory(dir string) *PriceHistory {
+	return &PriceHistory{
+		Dir:       dir,
+		snapshots: make(map[string]map[string]APIItem),
+	}
+}
+
+// Record stores items as the snapshot for the day of at, replacing any
+// earlier snapshot of that day
+func (h *PriceHistory) Record(items map[string]APIItem, at time.Time) error {
+	list := make([]APIItem, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	date := at.Format(historyDateFormat)
+	if err := writeFileAtomic(filepath.Join(h.Dir, date+".json"), data); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.snapshots[date] = indexByHistoryKey(list)
+	return nil
+}
+
+// Series returns the value of the item with the given slug (or name when it
+// has no slug) for every recorded day, oldest first
+func (h *PriceHistory) Series(key string) []PricePoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load()
+
+	var series []PricePoint
+	for _, date := range h.dates() {
+		item, ok := h.snapshots[date][key]
+		if !ok {
+			continue
+		}
+		day, _ := time.Parse(historyDateFormat, date)
+		series = append(series, PricePoint{Date: day, Value: item.HCVal})
+	}
+	return series
+}
+
+// Change returns the percentage change of an item's value between the most
+// recent snapshot and the latest snapshot at least days older than now
+func (h *PriceHistory) Change(key string, days int, now time.Time) (float64, bool) {
+	move, ok := h.move(key, days, now)
+	return move.Change, ok
+}
+
+// TopMovers returns the n items of keys whose value changed the most, in
+// either direction, over the last days. Equal changes are ordered by key.
+func (h *PriceHistory) TopMovers(keys []string, days int, n int, now time.Time) []PriceMove {
+	seen := make(map[string]bool)
+	var moves []PriceMove
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if move, ok := h.move(key, days, now); ok && move.Change != 0 {
+			moves = append(moves, move)
+		}
+	}
+
+	sort.Slice(moves, func(i, j int) bool {
+		a, b := math.Abs(moves[i].Change), math.Abs(moves[j].Change)
+		if a != b {
+			return a > b
+		}
+		return moves[i].Key < moves[j].Key
+	})
+	if n > 0 && len(moves) > n {
+		moves = moves[:n]
+	}
+	return moves
+}
+
+func (h *PriceHistory) move(key string, days int, now time.Time) (PriceMove, bool) {
+	series := h.Series(key)
+	if len(series) < 2 {
+		return PriceMove{}, false
+	}
+
+	latest := series[len(series)-1]
+	cutoff := now.AddDate(0, 0, -days)
+	var from *PricePoint
+	for i := len(series) - 2; i >= 0; i-- {
+		if !series[i].Date.After(cutoff) {
+			from = &series[i]
+			break
+		}
+	}
+	if from == nil || from.Value == 0 {
+		return PriceMove{}, false
+	}
+
+	h.mu.Lock()
+	name := h.snapshots[latest.Date.Format(historyDateFormat)][key].Name
+	h.mu.Unlock()
+
+	return PriceMove{
+		Key:    key,
+		Name:   name,
+		From:   from.Value,
+		To:     latest.Value,
+		Change: (latest.Value - from.Value).Float64() / from.Value.Float64() * 100,
+	}, true
+}
+
+// load reads every snapshot on disk the first time history is queried
+func (h *PriceHistory) load() {
+	if h.loaded {
+		return
+	}
+	h.loaded = true
+
+	files, err := ioutil.ReadDir(h.Dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		date := strings.TrimSuffix(file.Name(), ".json")
+		if _, err := time.Parse(historyDateFormat, date); err != nil {
+			continue
+		}
+		if _, ok := h.snapshots[date]; ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(h.Dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var list []APIItem
+		if err := json.Unmarshal(data, &list); err != nil {
+			continue
+		}
+		h.snapshots[date] = indexByHistoryKey(list)
+	}
+}
+
+func (h *PriceHistory) dates() []string {
+	dates := make([]string, 0, len(h.snapshots))
+	for date := range h.snapshots {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+func indexByHistoryKey(items []APIItem) map[string]APIItem {
+	result := make(map[string]APIItem, len(items))
+	for _, item := range items {
+		result[historyKey(item)] = item
+	}
+	return result
+}
+
+// historyKey identifies an API item across snapshots
+func historyKey(item APIItem) string {
+	if item.Slug != "" {
+		return item.Slug
+	}
+	return item.Name
+}
+
+// Trend returns the 7 and 30 day price change of an item priced by traderclub
+func (c *Catalog) Trend(key PriceKey) PriceTrend {
+	var trend PriceTrend
+	if c.apiPrices == nil {
+		return trend
+	}
+	item, _, ok := c.apiPrices.match(key)
+	if !ok {
+		return trend
+	}
+
+	now := time.Now()
+	if change, ok := PriceHistoryStore.Change(historyKey(item), 7, now); ok {
+		trend.Change7d = &change
+	}
+	if change, ok := PriceHistoryStore.Change(historyKey(item), 30, now); ok {
+		trend.Change30d = &change
+	}
+	return trend
+}
+
+// TopMovers returns the n items among keys whose traderclub value changed the
+// most over the last days
+func (c *Catalog) TopMovers(keys []PriceKey, days int, n int) []PriceMove {
+	if c.apiPrices == nil {
+		return nil
+	}
+	var historyKeys []string
+	for _, key := range keys {
+		if item, _, ok := c.apiPrices.match(key); ok {
+			historyKeys = append(historyKeys, historyKey(item))
+		}
+	}
+	return PriceHistoryStore.TopMovers(historyKeys, days, n, time.Now())
+}
@@ -0,0 +1,169 @@
+package common
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func historyDay(t *testing.T, date string) time.Time {
+	t.Helper()
+	day, err := time.Parse(historyDateFormat, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return day
+}
+
+// recordPrices records a snapshot of items keyed and named by slug
+func recordPrices(t *testing.T, h *PriceHistory, date string, prices map[string]HC) {
+	t.Helper()
+	items := make(map[string]APIItem, len(prices))
+	for slug, value := range prices {
+		items[slug] = APIItem{ID: len(items) + 1, Name: "The " + slug, Slug: slug, HCVal: value}
+	}
+	// Recorded in the middle of the day, snapshots are keyed by the date alone
+	if err := h.Record(items, historyDay(t, date).Add(15*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func seriesValues(series []PricePoint) map[string]HC {
+	values := make(map[string]HC, len(series))
+	for _, point := range series {
+		values[point.Date.Format(historyDateFormat)] = point.Value
+	}
+	return values
+}
+
+func TestPriceHistorySeries(t *testing.T) {
+	h := NewPriceHistory(t.TempDir())
+	recordPrices(t, h, "2024-03-10", map[string]HC{"throne": 5000, "chair": 25})
+	recordPrices(t, h, "2024-03-01", map[string]HC{"throne": 4000, "chair": 20})
+	recordPrices(t, h, "2024-03-05", map[string]HC{"throne": 4500})
+	// A second snapshot of the same day replaces the first
+	recordPrices(t, h, "2024-03-05", map[string]HC{"throne": 4600})
+
+	want := []PricePoint{
+		{Date: historyDay(t, "2024-03-01"), Value: 4000},
+		{Date: historyDay(t, "2024-03-05"), Value: 4600},
+		{Date: historyDay(t, "2024-03-10"), Value: 5000},
+	}
+	if got := h.Series("throne"); !reflect.DeepEqual(got, want) {
+		t.Errorf("throne series:\n got %v\nwant %v", got, want)
+	}
+	if got := seriesValues(h.Series("chair")); !reflect.DeepEqual(got, map[string]HC{"2024-03-01": 20, "2024-03-10": 25}) {
+		t.Errorf("chair series %v, want the days it was listed", got)
+	}
+	if got := h.Series("sofa"); len(got) != 0 {
+		t.Errorf("unknown item has series %v", got)
+	}
+
+	// Items without a slug are keyed by name
+	err := h.Record(map[string]APIItem{"Lamp": {ID: 9, Name: "Lamp", HCVal: 300}}, historyDay(t, "2024-03-11"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Series("Lamp"); len(got) != 1 || got[0].Value != 300 {
+		t.Errorf("lamp series %v", got)
+	}
+}
+
+func TestPriceHistoryChange(t *testing.T) {
+	h := NewPriceHistory(t.TempDir())
+	recordPrices(t, h, "2024-03-01", map[string]HC{"throne": 1000, "chair": 0})
+	recordPrices(t, h, "2024-03-05", map[string]HC{"throne": 1500, "chair": 20})
+	recordPrices(t, h, "2024-03-08", map[string]HC{"throne": 2000, "chair": 25})
+	now := historyDay(t, "2024-03-08")
+
+	tests := []struct {
+		key  string
+		days int
+		want float64
+		ok   bool
+	}{
+		// The snapshot exactly days old counts
+		{"throne", 7, 100, true},
+		// Otherwise the latest one older than days
+		{"throne", 6, 100, true},
+		{"throne", 3, 100.0 / 3, true},
+		{"throne", 1, 100.0 / 3, true},
+		// No snapshot is that old
+		{"throne", 30, 0, false},
+		// A change from zero has no percentage
+		{"chair", 7, 0, false},
+		{"chair", 3, 25, true},
+		{"sofa", 7, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := h.Change(tt.key, tt.days, now)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Change(%s, %d) = %v, %v, want %v, %v", tt.key, tt.days, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPriceHistoryTopMovers(t *testing.T) {
+	h := NewPriceHistory(t.TempDir())
+	recordPrices(t, h, "2024-03-01", map[string]HC{"throne": 1000, "chair": 100, "lamp": 100, "sofa": 100, "rug": 100})
+	recordPrices(t, h, "2024-03-08", map[string]HC{"throne": 1500, "chair": 50, "lamp": 150, "sofa": 100, "rug": 120})
+	now := historyDay(t, "2024-03-08")
+
+	keys := []string{"rug", "sofa", "throne", "lamp", "chair", "lamp", "unknown"}
+	moves := h.TopMovers(keys, 7, 0, now)
+	var order []string
+	for _, move := range moves {
+		order = append(order, move.Key)
+	}
+	// Biggest change in either direction first, ties by key, unchanged and
+	// unknown items left out and repeated keys listed once
+	if want := []string{"chair", "lamp", "throne", "rug"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("movers %v, want %v", order, want)
+	}
+	if want := (PriceMove{Key: "chair", Name: "The chair", From: 100, To: 50, Change: -50}); moves[0] != want {
+		t.Errorf("top mover %+v, want %+v", moves[0], want)
+	}
+
+	if top := h.TopMovers(keys, 7, 2, now); len(top) != 2 || top[1].Key != "lamp" {
+		t.Errorf("top 2: %+v", top)
+	}
+}
+
+func TestPriceHistoryLoadMergesRecorded(t *testing.T) {
+	dir := t.TempDir()
+	previous := NewPriceHistory(dir)
+	recordPrices(t, previous, "2024-03-01", map[string]HC{"throne": 4000})
+	recordPrices(t, previous, "2024-03-02", map[string]HC{"throne": 4100})
+	for name, content := range map[string]string{
+		"notes.json":      "[]",
+		"2024-03-03.json": "{",
+		"2024-03-04.txt":  "[]",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Snapshots recorded before the first query are kept alongside the ones
+	// loaded from disk
+	h := NewPriceHistory(dir)
+	recordPrices(t, h, "2024-03-02", map[string]HC{"throne": 4200})
+	recordPrices(t, h, "2024-03-05", map[string]HC{"throne": 4500})
+
+	want := map[string]HC{"2024-03-01": 4000, "2024-03-02": 4200, "2024-03-05": 4500}
+	if got := seriesValues(h.Series("throne")); !reflect.DeepEqual(got, want) {
+		t.Errorf("series %v, want %v", got, want)
+	}
+
+	// Later queries do not read the directory again
+	recordPrices(t, previous, "2024-03-06", map[string]HC{"throne": 4600})
+	if got := len(h.Series("throne")); got != 3 {
+		t.Errorf("%d points after another process recorded, want 3", got)
+	}
+	if got := len(NewPriceHistory(dir).Series("throne")); got != 4 {
+		t.Errorf("%d points on disk, want 4", got)
+	}
+}
@@ -0,0 +1,164 @@
+package common
+
+import (
+	"strings"
+	"sync"
+)
+
+// Hotel describes where the game data for one Origins hotel is published
+type Hotel struct {
+	ID               string
+	Name             string
+	Hosts            []string
+	VariablesURL     string
+	FurniDataURL     string
+	ExternalTextsURL string
+	IconBaseURL      string
+}
+
+// furniDataHash is the last known furnidata version. It is only used when
+// the location cannot be discovered from the hotel's variables index.
+const furniDataHash = "6e9408e1a9015a995c15203f246d8d2d61c5f72d"
+
+var Hotels = []Hotel{
+	{
+		ID:               "us",
+		Name:             "Habbo Origins (.com)",
+		Hosts:            []string{"game-ous.habbo.com"},
+		VariablesURL:     "https://origins-gamedata.habbo.com/external_variables/1",
+		FurniDataURL:     FurniDataBaseURL,
+		ExternalTextsURL: ExternalTextsURL,
+		IconBaseURL:      IconBaseURL,
+	},
+	{
+		ID:               "es",
+		Name:             "Habbo Origins (.es)",
+		Hosts:            []string{"game-oes.habbo.com"},
+		VariablesURL:     "https://origins-gamedata.habbo.es/external_variables/1",
+		FurniDataURL:     "https://origins.habbo.es/gamedata/furnidata_json/" + furniDataHash,
+		ExternalTextsURL: "https://origins-gamedata.habbo.es/external_texts/1",
+		IconBaseURL:      IconBaseURL,
+	},
+	{
+		ID:               "br",
+		Name:             "Habbo Origins (.com.br)",
+		Hosts:            []string{"game-obr.habbo.com"},
+		VariablesURL:     "https://origins-gamedata.habbo.com.br/external_variables/1",
+		FurniDataURL:     "https://origins.habbo.com.br/gamedata/furnidata_json/" + furniDataHash,
+		ExternalTextsURL: "https://origins-gamedata.habbo.com.br/external_texts/1",
+		IconBaseURL:      IconBaseURL,
+	},
+}
+
+// DefaultHotel is used when the connected host is unknown
+var DefaultHotel = Hotels[0]
+
+var (
+	hotelCatalogs   = make(map[string]*Catalog)
+	hotelCatalogsMu sync.Mutex
+)
+
+// HotelForHost returns the hotel a G-Earth connection host belongs to
+func HotelForHost(host string) Hotel {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		return DefaultHotel
+	}
+	for _, hotel := range Hotels {
+		for _, h := range hotel.Hosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return hotel
+			}
+		}
+	}
+	return DefaultHotel
+}
+
+// LoadCatalog returns the catalog for the hotel serving gameHost, loading it
+// on first use. Every hotel is cached on disk and in memory independently.
+func LoadCatalog(gameHost string) (*Catalog, error) {
+	hotel := HotelForHost(gameHost)
+
+	hotelCatalogsMu.Lock()
+	defer hotelCatalogsMu.Unlock()
+
+	if c, ok := hotelCatalogs[hotel.ID]; ok {
+		return c, nil
+	}
+
+	c, err := NewCatalog(sourceForHotel(hotel))
+	c.hotel = hotel
+	if err != nil {
+		return c, err
+	}
+	hotelCatalogs[hotel.ID] = c
+
+	return c, nil
+}
+
+// UseHotel makes the catalog for the hotel serving gameHost the default
+// catalog. It reports whether the default catalog changed. The previous
+// default is kept when the hotel's catalog fails to load.
+func UseHotel(gameHost string) (bool, error) {
+	hotel := HotelForHost(gameHost)
+	if DefaultCatalog().hotel.ID == hotel.ID {
+		return false, nil
+	}
+
+	c, err := LoadCatalog(gameHost)
+	if err != nil {
+		return false, err
+	}
+	SetDefaultCatalog(c)
+	return true, nil
+}
+
+// ReloadCatalog revalidates every data set of the hotel serving gameHost
+// against the network and builds a new catalog for it. Data sets that fail
+// to reload keep their previous contents. If the hotel is in use the new
+// catalog atomically replaces the default catalog.
+func ReloadCatalog(gameHost string) (*Catalog, error) {
+	hotel := HotelForHost(gameHost)
+
+	src := sourceForHotel(hotel)
+	if httpSource, ok := src.(*HTTPSource); ok {
+		revalidating := *httpSource
+		revalidating.Revalidate = true
+		src = &revalidating
+	}
+
+	c, err := NewCatalog(src)
+	c.hotel = hotel
+
+	hotelCatalogsMu.Lock()
+	defer hotelCatalogsMu.Unlock()
+
+	prev, ok := hotelCatalogs[hotel.ID]
+	if current := DefaultCatalog(); current.hotel.ID == hotel.ID {
+		prev, ok = current, true
+	}
+	if ok {
+		if c.furniData == nil {
+			c.setFurniData(prev.furniData)
+			c.status.FurniData = c.status.FurniData.keptPrevious(prev.furniData != nil)
+		}
+		if c.externalTexts == nil {
+			c.externalTexts = prev.externalTexts
+			c.status.ExternalTexts = c.status.ExternalTexts.keptPrevious(prev.externalTexts != nil)
+		}
+		if c.apiItems == nil {
+			c.setAPIItems(prev.apiItems)
+			c.status.Prices = c.status.Prices.keptPrevious(prev.apiItems != nil)
+		}
+	}
+
+	hotelCatalogs[hotel.ID] = c
+
+	loadMu.Lock()
+	if DefaultCatalog().hotel.ID == hotel.ID {
+		SetDefaultCatalog(c)
+	}
+	loadMu.Unlock()
+
+	return c, err
+}
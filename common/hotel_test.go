@@ -0,0 +1,113 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+// useHotelSources loads every hotel from the fixture game data, or from an
+// empty directory for the hotels in failing, for the rest of the test
+func useHotelSources(t *testing.T, failing ...string) {
+	t.Helper()
+	empty := t.TempDir()
+	previousSource, previousDefault := sourceForHotel, DefaultCatalog()
+	sourceForHotel = func(hotel Hotel) GameDataSource {
+		for _, id := range failing {
+			if hotel.ID == id {
+				return NewFileSource(empty)
+			}
+		}
+		return NewFileSource("testdata/gamedata")
+	}
+	resetHotelCatalogs := func() {
+		hotelCatalogsMu.Lock()
+		hotelCatalogs = make(map[string]*Catalog)
+		hotelCatalogsMu.Unlock()
+	}
+	resetHotelCatalogs()
+	t.Cleanup(func() {
+		sourceForHotel = previousSource
+		SetDefaultCatalog(previousDefault)
+		resetHotelCatalogs()
+	})
+}
+
+func TestHotelForHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"game-ous.habbo.com":         "us",
+		" GAME-OES.habbo.com ":       "es",
+		"origins.game-obr.habbo.com": "br",
+		"":                           DefaultHotel.ID,
+		"game-ous.habbo.com.evil":    DefaultHotel.ID,
+		"localhost":                  DefaultHotel.ID,
+	} {
+		if got := HotelForHost(host).ID; got != want {
+			t.Errorf("HotelForHost(%q) = %s, want %s", host, got, want)
+		}
+	}
+}
+
+func TestHotelURLs(t *testing.T) {
+	domains := map[string]string{"us": "habbo.com/", "es": "habbo.es/", "br": "habbo.com.br/"}
+	for _, hotel := range Hotels {
+		domain := domains[hotel.ID]
+		if domain == "" {
+			t.Errorf("unexpected hotel %s", hotel.ID)
+			continue
+		}
+		for name, url := range map[string]string{
+			"variables":      hotel.VariablesURL,
+			"furnidata":      hotel.FurniDataURL,
+			"external texts": hotel.ExternalTextsURL,
+		} {
+			if !strings.HasPrefix(url, "https://") || !strings.Contains(url, domain) {
+				t.Errorf("%s %s URL %q is not on %s", hotel.ID, name, url, domain)
+			}
+		}
+		if !strings.HasSuffix(hotel.FurniDataURL, "/"+furniDataHash) {
+			t.Errorf("%s furnidata URL %q does not use the pinned hash", hotel.ID, hotel.FurniDataURL)
+		}
+		if src := NewHotelSource(hotel); src.CachePrefix != hotel.ID || src.Hotel != hotel.ID {
+			t.Errorf("%s source caches under %q", hotel.ID, src.CachePrefix)
+		}
+	}
+}
+
+func TestUseHotel(t *testing.T) {
+	useHotelSources(t)
+
+	changed, err := UseHotel("game-oes.habbo.com")
+	if err != nil || !changed {
+		t.Fatalf("UseHotel: changed %v, %v", changed, err)
+	}
+	if DefaultCatalog().Hotel().ID != "es" {
+		t.Fatalf("default catalog is for %q", DefaultCatalog().Hotel().ID)
+	}
+	if changed, err := UseHotel("game-oes.habbo.com"); changed || err != nil {
+		t.Errorf("same hotel again: changed %v, %v", changed, err)
+	}
+
+	c, err := LoadCatalog("game-oes.habbo.com")
+	if err != nil || c != DefaultCatalog() {
+		t.Errorf("hotel catalog was not kept in memory: %v", err)
+	}
+}
+
+func TestUseHotelKeepsDefaultOnError(t *testing.T) {
+	useHotelSources(t, "br")
+	if _, err := UseHotel("game-oes.habbo.com"); err != nil {
+		t.Fatal(err)
+	}
+	previous := DefaultCatalog()
+
+	changed, err := UseHotel("game-obr.habbo.com")
+	if err == nil || changed {
+		t.Fatalf("failing hotel: changed %v, %v", changed, err)
+	}
+	if DefaultCatalog() != previous {
+		t.Errorf("default catalog replaced by the failed %q catalog", DefaultCatalog().Hotel().ID)
+	}
+	if _, ok := hotelCatalogs["br"]; ok {
+		t.Error("failed catalog was cached")
+	}
+}
@@ -0,0 +1,195 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IconPathPrefix is the asset server path icons are served under
+const IconPathPrefix = "/icons/"
+
+var (
+	iconRevisionPattern = regexp.MustCompile(`^[0-9]+$`)
+	iconFilePattern     = regexp.MustCompile(`^[A-Za-z0-9_.\-]+_icon\.png$`)
+)
+
+// IconPath returns the asset server path of an icon
+func IconPath(revision string, file string) string {
+	return IconPathPrefix + revision + "/" + file
+}
+
+// Icons is the icon cache served to the frontend
+var Icons = NewIconCache(filepath.Join(defaultCacheDir(), "icons"))
+
+// IconCache downloads furni icons on demand and keeps them on disk under
+// <Dir>/<revision>/<file>. Icons that cannot be downloaded are replaced by
+// the same icon from another revision if one is cached, or a placeholder.
+type IconCache struct {
+	Dir     string
+	BaseURL string
+	Fetcher *Fetcher
+
+	mu       sync.Mutex
+	inflight map[string]*iconFetch
+	missing  map[string]bool
+}
+
+type iconFetch struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+func NewIconCache(dir string) *IconCache {
+	return &IconCache{
+		Dir:      dir,
+		BaseURL:  IconBaseURL,
+		inflight: make(map[string]*iconFetch),
+		missing:  make(map[string]bool),
+	}
+}
+
+// Get returns the icon for revision and file, downloading it if it is not
+// cached yet. Concurrent requests for the same icon share one download.
+func (c *IconCache) Get(revision string, file string) ([]byte, error) {
+	if !iconRevisionPattern.MatchString(revision) || !iconFilePattern.MatchString(file) {
+		return nil, fmt.Errorf("invalid icon %s/%s", revision, file)
+	}
+
+	path := filepath.Join(c.Dir, revision, file)
+	if body, err := ioutil.ReadFile(path); err == nil {
+		return body, nil
+	}
+
+	key := revision + "/" + file
+	c.mu.Lock()
+	if c.missing[key] {
+		c.mu.Unlock()
+		return nil, os.ErrNotExist
+	}
+	if fetch, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-fetch.done
+		return fetch.body, fetch.err
+	}
+	fetch := &iconFetch{done: make(chan struct{})}
+	c.inflight[key] = fetch
+	c.mu.Unlock()
+
+	fetch.body, fetch.err = c.download(revision, file, path)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	var statusErr *HTTPStatusError
+	if errors.As(fetch.err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		c.missing[key] = true
+	}
+	c.mu.Unlock()
+	close(fetch.done)
+
+	return fetch.body, fetch.err
+}
+
+func (c *IconCache) download(revision string, file string, path string) ([]byte, error) {
+	if DataCache.Offline.Load() {
+		return nil, fmt.Errorf("offline mode: icon %s/%s is not cached", revision, file)
+	}
+
+	fetcher := c.Fetcher
+	if fetcher == nil {
+		fetcher = DefaultFetcher
+	}
+	body, err := fetcher.Get(fmt.Sprintf(c.BaseURL, revision) + file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(body)); err != nil {
+		return nil, &ParseError{URL: file, Err: err}
+	}
+
+	if err := writeFileAtomic(path, body); err != nil {
+		log.Printf("icons: failed to store %s/%s: %v", revision, file, err)
+	}
+	return body, nil
+}
+
+// fallback returns the same icon from any other cached revision
+func (c *IconCache) fallback(file string) ([]byte, bool) {
+	if !iconFilePattern.MatchString(file) {
+		return nil, false
+	}
+	matches, _ := filepath.Glob(filepath.Join(c.Dir, "*", file))
+	for i := len(matches) - 1; i >= 0; i-- {
+		if body, err := ioutil.ReadFile(matches[i]); err == nil {
+			return body, true
+		}
+	}
+	return nil, false
+}
+
+// ServeHTTP serves IconPathPrefix<revision>/<file> requests. It is used as
+// the asset server handler, so it only sees paths that are not part of the
+// bundled frontend.
+func (c *IconCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, IconPathPrefix)
+	parts := strings.Split(rest, "/")
+	if rest == r.URL.Path || len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := c.Get(parts[0], parts[1])
+	if err != nil {
+		var ok bool
+		if body, ok = c.fallback(parts[1]); !ok {
+			body = placeholderIcon()
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "max-age=86400")
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(body)
+}
+
+var (
+	placeholderOnce sync.Once
+	placeholderPNG  []byte
+)
+
+// placeholderIcon is a grey framed square shown for icons that are missing
+func placeholderIcon() []byte {
+	placeholderOnce.Do(func() {
+		const size = 32
+		img := image.NewNRGBA(image.Rect(0, 0, size, size))
+		frame := color.NRGBA{R: 0x9a, G: 0x9a, B: 0x9a, A: 0xff}
+		fill := color.NRGBA{R: 0xdd, G: 0xdd, B: 0xdd, A: 0xc0}
+		for y := 4; y < size-4; y++ {
+			for x := 4; x < size-4; x++ {
+				if x == 4 || y == 4 || x == size-5 || y == size-5 {
+					img.Set(x, y, frame)
+				} else {
+					img.Set(x, y, fill)
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		placeholderPNG = buf.Bytes()
+	})
+	return placeholderPNG
+}
@@ -0,0 +1,184 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func iconPNG(t *testing.T, c color.NRGBA) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, c)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// iconServer serves icons by <revision>/<file> path and 404 for the rest.
+// While gate is set, requests wait for it to be closed.
+type iconServer struct {
+	*httptest.Server
+	icons    map[string][]byte
+	gate     chan struct{}
+	requests atomic.Int32
+}
+
+func newIconServer(t *testing.T, icons map[string][]byte) *iconServer {
+	t.Helper()
+	s := &iconServer{icons: icons}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.gate != nil {
+			<-s.gate
+		}
+		body, ok := s.icons[r.URL.Path[1:]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestIconCache(t *testing.T, srv *iconServer) *IconCache {
+	t.Helper()
+	c := NewIconCache(t.TempDir())
+	c.BaseURL = srv.URL + "/%s/"
+	c.Fetcher = newTestFetcher()
+	return c
+}
+
+func TestIconCacheSharesDownloads(t *testing.T) {
+	throne := iconPNG(t, color.NRGBA{R: 0xff, A: 0xff})
+	srv := newIconServer(t, map[string][]byte{"20/throne_icon.png": throne})
+	srv.gate = make(chan struct{})
+	c := newTestIconCache(t, srv)
+
+	var wg sync.WaitGroup
+	bodies := make([][]byte, 10)
+	errs := make([]error, len(bodies))
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i], errs[i] = c.Get("20", "throne_icon.png")
+		}(i)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.requests.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(srv.gate)
+	wg.Wait()
+
+	for i := range bodies {
+		if errs[i] != nil || !bytes.Equal(bodies[i], throne) {
+			t.Errorf("get %d: %d bytes, %v", i, len(bodies[i]), errs[i])
+		}
+	}
+	if n := srv.requests.Load(); n != 1 {
+		t.Errorf("%d downloads of one icon", n)
+	}
+
+	// Stored on disk and read from there afterwards
+	if body, err := os.ReadFile(filepath.Join(c.Dir, "20", "throne_icon.png")); err != nil || !bytes.Equal(body, throne) {
+		t.Errorf("icon not stored: %v", err)
+	}
+	if _, err := c.Get("20", "throne_icon.png"); err != nil || srv.requests.Load() != 1 {
+		t.Errorf("cached icon downloaded again: %v, %d requests", err, srv.requests.Load())
+	}
+}
+
+func TestIconCacheRemembersMissing(t *testing.T) {
+	srv := newIconServer(t, map[string][]byte{"20/broken_icon.png": []byte("not a png")})
+	c := newTestIconCache(t, srv)
+
+	if _, err := c.Get("20", "ghost_icon.png"); err == nil {
+		t.Fatal("missing icon downloaded")
+	}
+	_, err := c.Get("20", "ghost_icon.png")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("second get: %v", err)
+	}
+	if n := srv.requests.Load(); n != 1 {
+		t.Errorf("missing icon requested %d times", n)
+	}
+
+	// Other failures are not remembered and not stored
+	for i := 0; i < 2; i++ {
+		var parseErr *ParseError
+		if _, err := c.Get("20", "broken_icon.png"); !errors.As(err, &parseErr) {
+			t.Errorf("broken icon: %v", err)
+		}
+	}
+	if n := srv.requests.Load(); n != 3 {
+		t.Errorf("%d requests, want the broken icon tried twice", n)
+	}
+	if _, err := os.Stat(filepath.Join(c.Dir, "20", "broken_icon.png")); !os.IsNotExist(err) {
+		t.Errorf("broken icon stored: %v", err)
+	}
+
+	for _, name := range [][2]string{{"2O", "throne_icon.png"}, {"20", "../throne_icon.png"}, {"20", "throne.gif"}} {
+		if _, err := c.Get(name[0], name[1]); err == nil {
+			t.Errorf("invalid icon %s/%s accepted", name[0], name[1])
+		}
+	}
+	if n := srv.requests.Load(); n != 3 {
+		t.Errorf("invalid icons were requested")
+	}
+}
+
+func TestIconCacheServeFallback(t *testing.T) {
+	current := iconPNG(t, color.NRGBA{G: 0xff, A: 0xff})
+	older := iconPNG(t, color.NRGBA{B: 0xff, A: 0xff})
+	srv := newIconServer(t, map[string][]byte{"20/chair_icon.png": current})
+	c := newTestIconCache(t, srv)
+	if err := writeFileAtomic(filepath.Join(c.Dir, "15", "throne_icon.png"), older); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path         string
+		status       int
+		body         []byte
+		cacheControl string
+	}{
+		{IconPath("20", "chair_icon.png"), http.StatusOK, current, "max-age=86400"},
+		// Missing from this revision, so the one cached from another is used
+		{IconPath("20", "throne_icon.png"), http.StatusOK, older, "no-cache"},
+		{IconPath("20", "ghost_icon.png"), http.StatusOK, placeholderIcon(), "no-cache"},
+		{IconPath("20", "throne.gif"), http.StatusOK, placeholderIcon(), "no-cache"},
+		{"/icons/throne_icon.png", http.StatusNotFound, nil, ""},
+		{"/index.html", http.StatusNotFound, nil, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status || rec.Header().Get("Cache-Control") != tt.cacheControl {
+			t.Errorf("%s: %d with Cache-Control %q", tt.path, rec.Code, rec.Header().Get("Cache-Control"))
+		}
+		if tt.body != nil && !bytes.Equal(rec.Body.Bytes(), tt.body) {
+			t.Errorf("%s: wrong icon served", tt.path)
+		}
+	}
+
+	if _, err := png.Decode(bytes.NewReader(placeholderIcon())); err != nil {
+		t.Errorf("placeholder is not a png: %v", err)
+	}
+}
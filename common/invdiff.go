@@ -0,0 +1,223 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InventoryDiff is what changed between two inventory snapshots. Values are
+// given twice: ValueThen uses the prices stored in each snapshot, ValueNow
+// values both snapshots at current prices so only item changes count.
+type InventoryDiff struct {
+	Hotel     string
+	Account   string
+	From      time.Time
+	To        time.Time
+	Gained    []DiffItem
+	Lost      []DiffItem
+	Groups    []GroupChange
+	ValueThen ValueChange
+	ValueNow  ValueChange
+}
+
+// DiffItem is an item gained or lost between two snapshots
+type DiffItem struct {
+	ItemId    int
+	GroupKey  string
+	Name      string
+	Rare      bool
+	ValueThen HC
+	ValueNow  HC
+}
+
+// GroupChange is the change in quantity and value of one inventory group
+type GroupChange struct {
+	GroupKey  string
+	Name      string
+	Before    int
+	After     int
+	Change    int
+	ValueThen HC
+	ValueNow  HC
+}
+
+// ValueChange is a total value before and after
+type ValueChange struct {
+	Before HC
+	After  HC
+	Change HC
+}
+
+func newValueChange(before HC, after HC) ValueChange {
+	return ValueChange{Before: before, After: after, Change: after - before}
+}
+
+// DiffInventory compares two snapshots of the same inventory, valuing the
+// current-price view with c
+func (c *Catalog) DiffInventory(from InventorySnapshot, to InventorySnapshot) InventoryDiff {
+	diff := InventoryDiff{
+		Hotel:   to.Hotel,
+		Account: to.Account,
+		From:    from.CapturedAt,
+		To:      to.CapturedAt,
+	}
+
+	priceNow := func(item InventorySnapshotItem) HC {
+		return c.EnrichInventoryItem(item.Item).HCValue
+	}
+	groupKey := func(item InventorySnapshotItem) string {
+		if item.GroupKey != "" {
+			return item.GroupKey
+		}
+		return InventoryGroupKey(item.Item)
+	}
+
+	groups := make(map[string]*GroupChange)
+	group := func(item InventorySnapshotItem) *GroupChange {
+		key := groupKey(item)
+		g, ok := groups[key]
+		if !ok {
+			g = &GroupChange{GroupKey: key, Name: item.Name}
+			groups[key] = g
+		}
+		return g
+	}
+
+	var nowBefore, nowAfter HC
+	before := make(map[int]InventorySnapshotItem, len(from.Items))
+	for _, item := range from.Items {
+		before[item.ItemId] = item
+		value := priceNow(item)
+		nowBefore += value
+
+		g := group(item)
+		g.Before++
+		g.ValueThen -= item.HCValue
+		g.ValueNow -= value
+	}
+
+	after := make(map[int]bool, len(to.Items))
+	for _, item := range to.Items {
+		after[item.ItemId] = true
+		value := priceNow(item)
+		nowAfter += value
+
+		g := group(item)
+		g.Name = item.Name
+		g.After++
+		g.ValueThen += item.HCValue
+		g.ValueNow += value
+
+		if _, ok := before[item.ItemId]; !ok {
+			diff.Gained = append(diff.Gained, newDiffItem(item, groupKey(item), value))
+		}
+	}
+	for _, item := range from.Items {
+		if !after[item.ItemId] {
+			diff.Lost = append(diff.Lost, newDiffItem(item, groupKey(item), priceNow(item)))
+		}
+	}
+
+	for _, g := range groups {
+		g.Change = g.After - g.Before
+		if g.Change != 0 || g.ValueThen != 0 {
+			diff.Groups = append(diff.Groups, *g)
+		}
+	}
+	sort.Slice(diff.Groups, func(i, j int) bool {
+		a, b := diff.Groups[i], diff.Groups[j]
+		if abs(a.Change) != abs(b.Change) {
+			return abs(a.Change) > abs(b.Change)
+		}
+		return a.GroupKey < b.GroupKey
+	})
+
+	diff.ValueThen = newValueChange(from.TotalValue, to.TotalValue)
+	diff.ValueNow = newValueChange(nowBefore, nowAfter)
+	return diff
+}
+
+// DiffInventory compares two snapshots using the default catalog
+func DiffInventory(from InventorySnapshot, to InventorySnapshot) InventoryDiff {
+	return DefaultCatalog().DiffInventory(from, to)
+}
+
+func newDiffItem(item InventorySnapshotItem, groupKey string, valueNow HC) DiffItem {
+	return DiffItem{
+		ItemId:    item.ItemId,
+		GroupKey:  groupKey,
+		Name:      item.Name,
+		Rare:      item.Rare,
+		ValueThen: item.HCValue,
+		ValueNow:  valueNow,
+	}
+}
+
+// RenderInventoryDiff renders a diff as markdown or json
+func RenderInventoryDiff(diff InventoryDiff, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatMarkdown:
+		return diff.Markdown(), nil
+	case FormatJSON:
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	default:
+		return "", fmt.Errorf("unknown diff format %q", format)
+	}
+}
+
+// Markdown renders the diff as a Markdown report
+func (d InventoryDiff) Markdown() string {
+	var b strings.Builder
+	b.WriteString("## Inventory changes\n\n")
+	if d.Account != "" {
+		b.WriteString(fmt.Sprintf("- **Account:** %s (%s)\n", markdownEscape(d.Account), d.Hotel))
+	}
+	b.WriteString(fmt.Sprintf("- **Period:** %s to %s\n", d.From.Format("2006-01-02 15:04"), d.To.Format("2006-01-02 15:04")))
+	b.WriteString(fmt.Sprintf("- **Items gained:** %d, **lost:** %d\n", len(d.Gained), len(d.Lost)))
+	b.WriteString(fmt.Sprintf("- **Value at the time:** %s → %s HC (%s)\n", d.ValueThen.Before, d.ValueThen.After, signedHC(d.ValueThen.Change)))
+	b.WriteString(fmt.Sprintf("- **Value at current prices:** %s → %s HC (%s)\n", d.ValueNow.Before, d.ValueNow.After, signedHC(d.ValueNow.Change)))
+
+	if len(d.Groups) > 0 {
+		b.WriteString("\n### Changes by item\n\n")
+		b.WriteString("| Item | Before | After | Change | HC then | HC now |\n")
+		b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, g := range d.Groups {
+			b.WriteString(fmt.Sprintf("| %s | %d | %d | %+d | %s | %s |\n",
+				markdownEscape(g.Name), g.Before, g.After, g.Change, signedHC(g.ValueThen), signedHC(g.ValueNow)))
+		}
+	}
+
+	for _, section := range []struct {
+		title string
+		items []DiffItem
+	}{{"Gained", d.Gained}, {"Lost", d.Lost}} {
+		if len(section.items) == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("\n### %s\n\n", section.title))
+		b.WriteString("| Item ID | Item | HC then | HC now |\n")
+		b.WriteString("| ---: | --- | ---: | ---: |\n")
+		for _, item := range section.items {
+			name := markdownEscape(item.Name)
+			if item.Rare {
+				name = "**★ " + name + "**"
+			}
+			b.WriteString(fmt.Sprintf("| %d | %s | %s | %s |\n", item.ItemId, name, item.ValueThen, item.ValueNow))
+		}
+	}
+	return b.String()
+}
+
+func signedHC(h HC) string {
+	if h > 0 {
+		return "+" + h.String()
+	}
+	return h.String()
+}
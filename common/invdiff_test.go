@@ -0,0 +1,157 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+var (
+	diffFrom = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	diffTo   = time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+)
+
+func throneItem(id int, value HC) InventorySnapshotItem {
+	return InventorySnapshotItem{Item: inventory.Item{ItemId: id, Class: "throne", Type: "S"}, Name: "Throne", HCValue: value, Rare: true}
+}
+
+func chairItem(id int, value HC) InventorySnapshotItem {
+	return InventorySnapshotItem{Item: inventory.Item{ItemId: id, Class: "chair_polyfon", Type: "S"}, Name: "Dining Chair", HCValue: value}
+}
+
+func garlandItem(id int, value HC) InventorySnapshotItem {
+	return InventorySnapshotItem{Item: inventory.Item{ItemId: id, Class: "poster", Type: "I", Props: "5003"}, Name: "Purple Garland", HCValue: value}
+}
+
+// diffFixture is a week in which a throne and a chair were traded away, and a
+// garland and another chair came in. Prices then differ from the fixture
+// catalog's current prices (throne 50, chair 0.25, garland 3.50).
+func diffFixture() (InventorySnapshot, InventorySnapshot) {
+	from := InventorySnapshot{
+		Hotel:      "us",
+		Account:    "alice",
+		CapturedAt: diffFrom,
+		TotalValue: 8020,
+		Items:      []InventorySnapshotItem{throneItem(1, 4000), throneItem(2, 4000), chairItem(3, 20)},
+	}
+	to := InventorySnapshot{
+		Hotel:      "us",
+		Account:    "alice",
+		CapturedAt: diffTo,
+		TotalValue: 4825,
+		Items:      []InventorySnapshotItem{throneItem(1, 4500), garlandItem(4, 300), chairItem(5, 25)},
+	}
+	return from, to
+}
+
+func TestDiffInventoryGainedAndLost(t *testing.T) {
+	from, to := diffFixture()
+	diff := loadFixtureCatalog(t).DiffInventory(from, to)
+
+	wantGained := []DiffItem{
+		{ItemId: 4, GroupKey: "poster_5003", Name: "Purple Garland", ValueThen: 300, ValueNow: 350},
+		{ItemId: 5, GroupKey: "chair_polyfon", Name: "Dining Chair", ValueThen: 25, ValueNow: 25},
+	}
+	wantLost := []DiffItem{
+		{ItemId: 2, GroupKey: "throne", Name: "Throne", Rare: true, ValueThen: 4000, ValueNow: 5000},
+		{ItemId: 3, GroupKey: "chair_polyfon", Name: "Dining Chair", ValueThen: 20, ValueNow: 25},
+	}
+	if !reflect.DeepEqual(diff.Gained, wantGained) {
+		t.Errorf("gained:\n got %+v\nwant %+v", diff.Gained, wantGained)
+	}
+	if !reflect.DeepEqual(diff.Lost, wantLost) {
+		t.Errorf("lost:\n got %+v\nwant %+v", diff.Lost, wantLost)
+	}
+	if diff.Hotel != "us" || diff.Account != "alice" || !diff.From.Equal(diffFrom) || !diff.To.Equal(diffTo) {
+		t.Errorf("header %s %s %v %v", diff.Hotel, diff.Account, diff.From, diff.To)
+	}
+}
+
+func TestDiffInventoryGroups(t *testing.T) {
+	from, to := diffFixture()
+	diff := loadFixtureCatalog(t).DiffInventory(from, to)
+
+	// Ordered by the size of the quantity change, then by key. The chair
+	// group kept its quantity but is listed because its value changed.
+	want := []GroupChange{
+		{GroupKey: "poster_5003", Name: "Purple Garland", Before: 0, After: 1, Change: 1, ValueThen: 300, ValueNow: 350},
+		{GroupKey: "throne", Name: "Throne", Before: 2, After: 1, Change: -1, ValueThen: -3500, ValueNow: -5000},
+		{GroupKey: "chair_polyfon", Name: "Dining Chair", Before: 1, After: 1, Change: 0, ValueThen: 5, ValueNow: 0},
+	}
+	if !reflect.DeepEqual(diff.Groups, want) {
+		t.Errorf("groups:\n got %+v\nwant %+v", diff.Groups, want)
+	}
+}
+
+func TestDiffInventoryValues(t *testing.T) {
+	from, to := diffFixture()
+	diff := loadFixtureCatalog(t).DiffInventory(from, to)
+
+	// Then uses the totals stored in the snapshots, now values both
+	// snapshots at the catalog's current prices
+	if want := (ValueChange{Before: 8020, After: 4825, Change: -3195}); diff.ValueThen != want {
+		t.Errorf("value then %+v, want %+v", diff.ValueThen, want)
+	}
+	if want := (ValueChange{Before: 10025, After: 5375, Change: -4650}); diff.ValueNow != want {
+		t.Errorf("value now %+v, want %+v", diff.ValueNow, want)
+	}
+}
+
+func TestDiffInventoryUnchanged(t *testing.T) {
+	c := loadFixtureCatalog(t)
+	items := map[int]inventory.Item{
+		1: {ItemId: 1, Class: "throne", Type: "S"},
+		2: {ItemId: 2, Class: "poster", Type: "I", Props: "5003"},
+	}
+	from := c.NewInventorySnapshot("alice", items)
+	to := c.NewInventorySnapshot("alice", items)
+
+	diff := c.DiffInventory(from, to)
+	if len(diff.Gained) != 0 || len(diff.Lost) != 0 || len(diff.Groups) != 0 {
+		t.Errorf("unchanged inventory has changes: %+v", diff)
+	}
+	if diff.ValueThen.Change != 0 || diff.ValueNow.Change != 0 || diff.ValueNow.After != 5350 {
+		t.Errorf("value then %+v, now %+v", diff.ValueThen, diff.ValueNow)
+	}
+}
+
+func TestRenderInventoryDiff(t *testing.T) {
+	from, to := diffFixture()
+	diff := loadFixtureCatalog(t).DiffInventory(from, to)
+
+	md, err := RenderInventoryDiff(diff, FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"- **Items gained:** 2, **lost:** 2\n",
+		"- **Value at the time:** 80.20 → 48.25 HC (-31.95)\n",
+		"| Purple Garland | 0 | 1 | +1 | +3.00 | +3.50 |\n",
+		"| Throne | 2 | 1 | -1 | -35.00 | -50.00 |\n",
+		"| 2 | **★ Throne** | 40.00 | 50.00 |\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, md)
+		}
+	}
+
+	js, err := RenderInventoryDiff(diff, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded InventoryDiff
+	if err := json.Unmarshal([]byte(js), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Groups, diff.Groups) || decoded.ValueNow != diff.ValueNow {
+		t.Errorf("json does not round trip:\n%s", js)
+	}
+
+	if _, err := RenderInventoryDiff(diff, "csv"); err == nil {
+		t.Error("unknown format rendered")
+	}
+}
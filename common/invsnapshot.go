@@ -0,0 +1,265 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+// InventorySnapshotVersion is the format version written by
+// SaveInventorySnapshot. Snapshots with a newer version are rejected.
+const InventorySnapshotVersion = 1
+
+// maxInventorySnapshots is how many snapshots are kept per hotel and account
+const maxInventorySnapshots = 50
+
+// InventorySnapshot is the inventory of one account as of a completed scan,
+// with every item valued at the prices of that time
+type InventorySnapshot struct {
+	Version      int
+	Hotel        string
+	Account      string
+	CapturedAt   time.Time
+	TotalItems   int
+	TotalValue   HC
+	PriceSources []string
+	PriceData    DataStatus
+	Items        []InventorySnapshotItem
+}
+
+// InventorySnapshotItem is an item of an InventorySnapshot
+type InventorySnapshotItem struct {
+	inventory.Item
+	GroupKey    string
+	Name        string
+	HCValue     HC
+	PriceSource string
+	Rare        bool
+}
+
+// NewInventorySnapshot values items and captures them for account, ordered
+// by item id so equal inventories produce equal snapshots
+func (c *Catalog) NewInventorySnapshot(account string, items map[int]inventory.Item) InventorySnapshot {
+	snapshot := InventorySnapshot{
+		Version:    InventorySnapshotVersion,
+		Hotel:      c.hotel.ID,
+		Account:    account,
+		CapturedAt: time.Now(),
+		PriceData:  c.status.Prices,
+	}
+
+	sources := make(map[string]bool)
+	for _, item := range items {
+		enriched := c.EnrichInventoryItem(item)
+		snapshot.Items = append(snapshot.Items, InventorySnapshotItem{
+			Item:        item,
+			GroupKey:    enriched.GroupKey,
+			Name:        enriched.Name,
+			HCValue:     enriched.HCValue,
+			PriceSource: enriched.PriceSource,
+			Rare:        enriched.Rare,
+		})
+		snapshot.TotalItems++
+		snapshot.TotalValue += enriched.HCValue
+		if enriched.PriceSource != "" {
+			sources[enriched.PriceSource] = true
+		}
+	}
+	snapshot.PriceSources = sortedKeys(sources)
+
+	sort.Slice(snapshot.Items, func(i, j int) bool { return snapshot.Items[i].ItemId < snapshot.Items[j].ItemId })
+	return snapshot
+}
+
+// NewInventorySnapshot captures an inventory using the default catalog
+func NewInventorySnapshot(account string, items map[int]inventory.Item) InventorySnapshot {
+	return DefaultCatalog().NewInventorySnapshot(account, items)
+}
+
+// InventoryItems returns the snapshot's items keyed by item id
+func (s InventorySnapshot) InventoryItems() map[int]inventory.Item {
+	items := make(map[int]inventory.Item, len(s.Items))
+	for _, item := range s.Items {
+		items[item.ItemId] = item.Item
+	}
+	return items
+}
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// InventorySnapshotDir is where the snapshots of an account on a hotel are
+// stored, one file per scan
+func InventorySnapshotDir(hotel string, account string) string {
+	if hotel == "" {
+		hotel = "unknown"
+	}
+	if account == "" {
+		account = "unknown"
+	}
+	return filepath.Join(ConfigDir(), "inventory", unsafePathChars.ReplaceAllString(hotel, "_"), unsafePathChars.ReplaceAllString(account, "_"))
+}
+
+// lastInventorySnapshotPath records which snapshot was saved last, so it can
+// be restored before the hotel and account are known
+func lastInventorySnapshotPath() string {
+	return filepath.Join(ConfigDir(), "inventory", "last.json")
+}
+
+// SaveInventorySnapshot stores a snapshot next to the earlier ones of its
+// hotel and account, drops the oldest beyond maxInventorySnapshots and
+// returns the path it was saved to
+func SaveInventorySnapshot(snapshot InventorySnapshot) (string, error) {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir := InventorySnapshotDir(snapshot.Hotel, snapshot.Account)
+	path := filepath.Join(dir, snapshot.CapturedAt.UTC().Format("20060102-150405.000")+".json")
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", err
+	}
+
+	if err := writeFileAtomic(lastInventorySnapshotPath(), []byte(fmt.Sprintf("%q", path))); err != nil {
+		log.Printf("inventory: failed to record last snapshot: %v", err)
+	}
+
+	paths, _ := inventorySnapshotPaths(dir)
+	for len(paths) > maxInventorySnapshots {
+		os.Remove(paths[0])
+		paths = paths[1:]
+	}
+	return path, nil
+}
+
+// LoadInventorySnapshot reads a snapshot written by SaveInventorySnapshot
+func LoadInventorySnapshot(path string) (InventorySnapshot, error) {
+	var snapshot InventorySnapshot
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, &ParseError{URL: path, Err: err}
+	}
+	if snapshot.Version > InventorySnapshotVersion {
+		return snapshot, &ParseError{URL: path, Err: fmt.Errorf("unsupported snapshot version %d", snapshot.Version)}
+	}
+	return snapshot, nil
+}
+
+// LastInventorySnapshot loads the snapshot saved last, whatever its hotel and
+// account
+func LastInventorySnapshot() (InventorySnapshot, error) {
+	data, err := ioutil.ReadFile(lastInventorySnapshotPath())
+	if err != nil {
+		return InventorySnapshot{}, err
+	}
+	var path string
+	if err := json.Unmarshal(data, &path); err != nil {
+		return InventorySnapshot{}, &ParseError{URL: lastInventorySnapshotPath(), Err: err}
+	}
+	return LoadInventorySnapshot(path)
+}
+
+// InventorySnapshotInfo describes a saved snapshot without its items
+type InventorySnapshotInfo struct {
+	Path         string
+	Hotel        string
+	Account      string
+	CapturedAt   time.Time
+	TotalItems   int
+	TotalValue   HC
+	PriceSources []string
+}
+
+// ListInventorySnapshots describes the saved snapshots of an account on a
+// hotel, oldest first
+func ListInventorySnapshots(hotel string, account string) ([]InventorySnapshotInfo, error) {
+	paths, err := inventorySnapshotPaths(InventorySnapshotDir(hotel, account))
+	if err != nil {
+		return nil, err
+	}
+	var infos []InventorySnapshotInfo
+	for _, path := range paths {
+		snapshot, err := LoadInventorySnapshot(path)
+		if err != nil {
+			log.Printf("inventory: skipping snapshot: %v", err)
+			continue
+		}
+		infos = append(infos, InventorySnapshotInfo{
+			Path:         path,
+			Hotel:        snapshot.Hotel,
+			Account:      snapshot.Account,
+			CapturedAt:   snapshot.CapturedAt,
+			TotalItems:   snapshot.TotalItems,
+			TotalValue:   snapshot.TotalValue,
+			PriceSources: snapshot.PriceSources,
+		})
+	}
+	return infos, nil
+}
+
+// inventorySnapshotPaths lists the snapshot files in dir, oldest first. The
+// file names are timestamps, so name order is time order.
+func inventorySnapshotPaths(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var paths []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
+			paths = append(paths, filepath.Join(dir, file.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// InventoryReconciliation is how a fresh scan differs from the snapshot that
+// was shown until it completed
+type InventoryReconciliation struct {
+	SnapshotAt      time.Time
+	SameAccount     bool
+	Added           int
+	Removed         int
+	Unchanged       int
+	SnapshotValue   HC
+	CurrentValue    HC
+	ValueDifference HC
+}
+
+// Reconcile compares a fresh scan with the snapshot
+func (s InventorySnapshot) Reconcile(fresh InventorySnapshot) InventoryReconciliation {
+	r := InventoryReconciliation{
+		SnapshotAt:    s.CapturedAt,
+		SameAccount:   s.Hotel == fresh.Hotel && s.Account == fresh.Account,
+		SnapshotValue: s.TotalValue,
+		CurrentValue:  fresh.TotalValue,
+	}
+	r.ValueDifference = r.CurrentValue - r.SnapshotValue
+
+	old := s.InventoryItems()
+	for _, item := range fresh.Items {
+		if _, ok := old[item.ItemId]; ok {
+			r.Unchanged++
+			delete(old, item.ItemId)
+		} else {
+			r.Added++
+		}
+	}
+	r.Removed = len(old)
+	return r
+}
@@ -0,0 +1,166 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+// useConfigDir points ConfigDir at a temporary directory for the rest of the
+// test
+func useConfigDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, env := range []string{"XDG_CONFIG_HOME", "APPDATA", "HOME"} {
+		t.Setenv(env, dir)
+	}
+}
+
+func snapshotFixture(t *testing.T) InventorySnapshot {
+	t.Helper()
+	c := loadFixtureCatalog(t)
+	c.hotel = DefaultHotel
+	usePricing(t, DefaultPricingConfig)
+	snapshot := c.NewInventorySnapshot("alice", map[int]inventory.Item{
+		3: {ItemId: 3, Class: "poster", Type: "I", Props: "5003"},
+		1: {ItemId: 1, Class: "throne", Type: "S"},
+		2: {ItemId: 2, Class: "chair_polyfon", Type: "S"},
+	})
+	snapshot.CapturedAt = diffFrom
+	return snapshot
+}
+
+func TestNewInventorySnapshot(t *testing.T) {
+	snapshot := snapshotFixture(t)
+	if snapshot.Hotel != "us" || snapshot.Account != "alice" || snapshot.Version != InventorySnapshotVersion {
+		t.Errorf("header %s %s version %d", snapshot.Hotel, snapshot.Account, snapshot.Version)
+	}
+	if snapshot.TotalItems != 3 || snapshot.TotalValue != 5375 || !reflect.DeepEqual(snapshot.PriceSources, []string{SourceTraderClub}) {
+		t.Errorf("%d items worth %s from %v", snapshot.TotalItems, snapshot.TotalValue, snapshot.PriceSources)
+	}
+	want := []InventorySnapshotItem{
+		throneItem(1, 5000),
+		chairItem(2, 25),
+		garlandItem(3, 350),
+	}
+	for i := range want {
+		want[i].GroupKey = snapshot.Items[i].GroupKey
+		want[i].PriceSource = SourceTraderClub
+	}
+	if !reflect.DeepEqual(snapshot.Items, want) {
+		t.Errorf("items:\n got %+v\nwant %+v", snapshot.Items, want)
+	}
+}
+
+func TestInventorySnapshotRoundTrip(t *testing.T) {
+	useConfigDir(t)
+	snapshot := snapshotFixture(t)
+
+	path, err := SaveInventorySnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != InventorySnapshotDir("us", "alice") {
+		t.Errorf("saved to %s", path)
+	}
+
+	loaded, err := LoadInventorySnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, snapshot) {
+		t.Errorf("loaded:\n %+v\nwant %+v", loaded, snapshot)
+	}
+	if !reflect.DeepEqual(loaded.InventoryItems(), map[int]inventory.Item{
+		1: {ItemId: 1, Class: "throne", Type: "S"},
+		2: {ItemId: 2, Class: "chair_polyfon", Type: "S"},
+		3: {ItemId: 3, Class: "poster", Type: "I", Props: "5003"},
+	}) {
+		t.Errorf("inventory items %+v", loaded.InventoryItems())
+	}
+
+	last, err := LastInventorySnapshot()
+	if err != nil || !reflect.DeepEqual(last, snapshot) {
+		t.Errorf("last snapshot %+v, %v", last, err)
+	}
+
+	infos, err := ListInventorySnapshots("us", "alice")
+	if err != nil || len(infos) != 1 {
+		t.Fatalf("listed %+v, %v", infos, err)
+	}
+	if info := infos[0]; info.Path != path || info.TotalValue != snapshot.TotalValue || !info.CapturedAt.Equal(diffFrom) {
+		t.Errorf("info %+v", info)
+	}
+}
+
+func TestLoadInventorySnapshotErrors(t *testing.T) {
+	useConfigDir(t)
+	if _, err := LastInventorySnapshot(); !os.IsNotExist(err) {
+		t.Errorf("no snapshot saved yet: %v", err)
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"newer.json":   `{"Version": 2, "Account": "alice"}`,
+		"corrupt.json": `{"Version": 1, "Items": [`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var parseErr *ParseError
+		if _, err := LoadInventorySnapshot(path); !errors.As(err, &parseErr) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestSaveInventorySnapshotKeepsNewest(t *testing.T) {
+	useConfigDir(t)
+	snapshot := snapshotFixture(t)
+	for i := 0; i < maxInventorySnapshots+2; i++ {
+		snapshot.CapturedAt = diffFrom.Add(time.Duration(i) * time.Minute)
+		if _, err := SaveInventorySnapshot(snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := ListInventorySnapshots("us", "alice")
+	if err != nil || len(infos) != maxInventorySnapshots {
+		t.Fatalf("%d snapshots kept, %v", len(infos), err)
+	}
+	if !infos[0].CapturedAt.Equal(diffFrom.Add(2 * time.Minute)) {
+		t.Errorf("oldest kept snapshot is from %v", infos[0].CapturedAt)
+	}
+	if last, _ := LastInventorySnapshot(); !last.CapturedAt.Equal(snapshot.CapturedAt) {
+		t.Errorf("last snapshot is from %v", last.CapturedAt)
+	}
+}
+
+func TestInventorySnapshotReconcile(t *testing.T) {
+	from, to := diffFixture()
+	r := from.Reconcile(to)
+	want := InventoryReconciliation{
+		SnapshotAt:      diffFrom,
+		SameAccount:     true,
+		Added:           2,
+		Removed:         2,
+		Unchanged:       1,
+		SnapshotValue:   8020,
+		CurrentValue:    4825,
+		ValueDifference: -3195,
+	}
+	if r != want {
+		t.Errorf("reconciliation %+v, want %+v", r, want)
+	}
+
+	to.Account = "bob"
+	if from.Reconcile(to).SameAccount {
+		t.Error("snapshots of different accounts reconciled as the same account")
+	}
+}
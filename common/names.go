@@ -0,0 +1,200 @@
+package common
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NameAliasFile is the name of the user's name aliases in the config
+// directory. Its entries are merged over the built-in data/names.json.
+const NameAliasFile = "names.json"
+
+// Steps of the name resolution chain reported by ResolvedText.Source
+const (
+	TextFromExternalTexts = "external_texts"
+	TextFromFurniData     = "furnidata"
+	TextFromAlias         = "alias"
+	TextFromClassName     = "classname"
+	TextUnresolved        = "none"
+)
+
+//go:embed data/names.json
+var builtinNameAliases []byte
+
+// nameAliases maps a classname (poster_<id> for posters) to a display name
+// for items the game data has no name for
+var nameAliases = loadNameAliases()
+
+func loadNameAliases() map[string]string {
+	aliases := make(map[string]string)
+	if err := json.Unmarshal(builtinNameAliases, &aliases); err != nil {
+		log.Printf("names: built-in name aliases are invalid: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(ConfigDir(), NameAliasFile))
+	if err == nil {
+		var user map[string]string
+		if err := json.Unmarshal(data, &user); err != nil {
+			log.Printf("names: ignoring %s: %v", NameAliasFile, err)
+		}
+		for key, name := range user {
+			aliases[key] = name
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("names: %v", err)
+	}
+
+	return aliases
+}
+
+// ResolvedText is a name or description together with the step of the
+// resolution chain that produced it
+type ResolvedText struct {
+	Text   string
+	Source string
+}
+
+// ResolveName finds the display name of an item by trying the external
+// texts, the furnidata name, the alias table and finally the classname.
+// Names that fall through to the classname are recorded in NameDiagnostics.
+func (c *Catalog) ResolveName(class string, itemType string, props string) ResolvedText {
+	name := c.resolveName(class, itemType, props)
+	if name.Source == TextFromClassName {
+		NameDiagnostics.record(c.hotel.ID, class, itemType, props)
+	}
+	return name
+}
+
+func (c *Catalog) resolveName(class string, itemType string, props string) ResolvedText {
+	if name, ok := c.lookupText(class, itemType, props, "name"); ok {
+		return ResolvedText{Text: name, Source: TextFromExternalTexts}
+	}
+	if furni, ok := c.Furni(class, itemType); ok && furni.Name != "" && !isPoster(class, props) {
+		return ResolvedText{Text: furni.Name, Source: TextFromFurniData}
+	}
+
+	key := PriceKey{ClassName: class, ItemType: itemType, Props: props}
+	if name, ok := nameAliases[key.Key()]; ok && name != "" {
+		return ResolvedText{Text: name, Source: TextFromAlias}
+	}
+
+	if itemType == "I" {
+		return ResolvedText{Text: fmt.Sprintf("%s_%s", class, props), Source: TextFromClassName}
+	}
+	return ResolvedText{Text: class, Source: TextFromClassName}
+}
+
+// isPoster reports whether an item is one of the posters that share the
+// "poster" furnidata entry, whose name says nothing about the poster itself
+func isPoster(class string, props string) bool {
+	return class == "poster" && props != ""
+}
+
+// ResolveDescription finds the description of an item in the external texts
+// or, failing that, in the furnidata
+func (c *Catalog) ResolveDescription(class string, itemType string, props string) ResolvedText {
+	if desc, ok := c.lookupText(class, itemType, props, "desc"); ok {
+		return ResolvedText{Text: desc, Source: TextFromExternalTexts}
+	}
+	if furni, ok := c.Furni(class, itemType); ok && furni.Description != "" && !isPoster(class, props) {
+		return ResolvedText{Text: furni.Description, Source: TextFromFurniData}
+	}
+	return ResolvedText{Source: TextUnresolved}
+}
+
+// UnresolvedName is an item whose name could only be shown as its classname
+type UnresolvedName struct {
+	Hotel     string
+	ClassName string
+	ItemType  string
+	Props     string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// UnresolvedNames collects the items name resolution fell through for
+type UnresolvedNames struct {
+	mu      sync.Mutex
+	entries map[string]*UnresolvedName
+}
+
+// NameDiagnostics records every unresolved name seen since startup
+var NameDiagnostics = NewUnresolvedNames()
+
+func NewUnresolvedNames() *UnresolvedNames {
+	return &UnresolvedNames{entries: make(map[string]*UnresolvedName)}
+}
+
+func (u *UnresolvedNames) record(hotel string, class string, itemType string, props string) {
+	key := fmt.Sprintf("%s/%s/%s/%s", hotel, itemType, class, props)
+	now := time.Now()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if entry, ok := u.entries[key]; ok {
+		entry.LastSeen = now
+		return
+	}
+	u.entries[key] = &UnresolvedName{
+		Hotel:     hotel,
+		ClassName: class,
+		ItemType:  itemType,
+		Props:     props,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+}
+
+// All returns the recorded names sorted by hotel and classname
+func (u *UnresolvedNames) All() []UnresolvedName {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	result := make([]UnresolvedName, 0, len(u.entries))
+	for _, entry := range u.entries {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Hotel != b.Hotel {
+			return a.Hotel < b.Hotel
+		}
+		if a.ClassName != b.ClassName {
+			return a.ClassName < b.ClassName
+		}
+		return a.Props < b.Props
+	})
+	return result
+}
+
+// NameReport lists the items of this catalog's hotel that were shown by
+// classname and still have no name, for example because the external texts
+// lack them and no alias was added yet
+type NameReport struct {
+	Hotel      string
+	Unresolved []UnresolvedName
+}
+
+// NameReport checks the recorded unresolved names against the catalog, so
+// names fixed by a reload or a new alias drop out of the report
+func (c *Catalog) NameReport() NameReport {
+	report := NameReport{Hotel: c.hotel.ID}
+	for _, entry := range NameDiagnostics.All() {
+		if entry.Hotel != c.hotel.ID {
+			continue
+		}
+		if c.resolveName(entry.ClassName, entry.ItemType, entry.Props).Source != TextFromClassName {
+			continue
+		}
+		report.Unresolved = append(report.Unresolved, entry)
+	}
+	return report
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+// namesCatalog makes a catalog of the us hotel for name resolution tests, with
+// its own aliases and diagnostics for the rest of the test
+func namesCatalog(t *testing.T, aliases map[string]string) *Catalog {
+	t.Helper()
+	furni := []FurniData{
+		{ClassName: "throne", Name: "Throne"},
+		{ClassName: "chair_polyfon", Name: "Dining Chair"},
+		{ClassName: "sofa", Name: "Sofa"},
+		{ClassName: "poster", Name: "Poster", WallItem: true},
+		{ClassName: "lamp"},
+	}
+	texts := map[string]string{
+		"furni_throne_name":    "Royal Throne",
+		"furni_sofa_name":      "",
+		"poster_5003_name":     "Purple Garland",
+		"wallitem_window_name": "Window",
+	}
+	c, err := NewCatalog(NewMemorySource(furni, texts, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.hotel = DefaultHotel
+
+	previousAliases, previousDiagnostics := nameAliases, NameDiagnostics
+	nameAliases, NameDiagnostics = aliases, NewUnresolvedNames()
+	t.Cleanup(func() { nameAliases, NameDiagnostics = previousAliases, previousDiagnostics })
+	return c
+}
+
+func TestResolveName(t *testing.T) {
+	c := namesCatalog(t, map[string]string{
+		"poster_5000": "Green Garland",
+		"poster_5003": "Alias Garland",
+		"lamp":        "Table Lamp",
+		"throne":      "Alias Throne",
+		"ghost":       "",
+	})
+
+	tests := []struct {
+		class    string
+		itemType string
+		props    string
+		want     ResolvedText
+	}{
+		// External texts win over the furnidata and the aliases
+		{"throne", "S", "", ResolvedText{"Royal Throne", TextFromExternalTexts}},
+		{"poster", "I", "5003", ResolvedText{"Purple Garland", TextFromExternalTexts}},
+		{"window", "I", "", ResolvedText{"Window", TextFromExternalTexts}},
+		// Then the furnidata name, also when the text is there but empty
+		{"chair_polyfon", "S", "", ResolvedText{"Dining Chair", TextFromFurniData}},
+		{"sofa", "S", "", ResolvedText{"Sofa", TextFromFurniData}},
+		// Then the aliases, also for posters whose shared furnidata name says
+		// nothing about them
+		{"lamp", "S", "", ResolvedText{"Table Lamp", TextFromAlias}},
+		{"poster", "I", "5000", ResolvedText{"Green Garland", TextFromAlias}},
+		// And finally the classname
+		{"ghost", "S", "", ResolvedText{"ghost", TextFromClassName}},
+		{"poster", "I", "9999", ResolvedText{"poster_9999", TextFromClassName}},
+	}
+	for _, tt := range tests {
+		if got := c.ResolveName(tt.class, tt.itemType, tt.props); got != tt.want {
+			t.Errorf("ResolveName(%s, %s, %s) = %+v, want %+v", tt.class, tt.itemType, tt.props, got, tt.want)
+		}
+	}
+}
+
+func TestResolveDescription(t *testing.T) {
+	c := namesCatalog(t, nil)
+	c.externalTexts["furni_throne_desc"] = "Important Habbos only"
+	c.furniData["chair_polyfon"] = FurniData{ClassName: "chair_polyfon", Name: "Dining Chair", Description: "Sit down"}
+	c.furniData["poster"] = FurniData{ClassName: "poster", Name: "Poster", Description: "Any poster", WallItem: true}
+
+	for _, tt := range []struct {
+		class    string
+		itemType string
+		props    string
+		want     ResolvedText
+	}{
+		{"throne", "S", "", ResolvedText{"Important Habbos only", TextFromExternalTexts}},
+		{"chair_polyfon", "S", "", ResolvedText{"Sit down", TextFromFurniData}},
+		{"poster", "I", "5003", ResolvedText{"", TextUnresolved}},
+		{"ghost", "S", "", ResolvedText{"", TextUnresolved}},
+	} {
+		if got := c.ResolveDescription(tt.class, tt.itemType, tt.props); got != tt.want {
+			t.Errorf("ResolveDescription(%s, %s, %s) = %+v, want %+v", tt.class, tt.itemType, tt.props, got, tt.want)
+		}
+	}
+}
+
+func TestNameDiagnostics(t *testing.T) {
+	c := namesCatalog(t, map[string]string{"lamp": "Table Lamp"})
+
+	c.ResolveName("throne", "S", "")
+	c.ResolveName("lamp", "S", "")
+	c.ResolveName("poster", "I", "9999")
+	c.ResolveName("ghost", "S", "")
+	first := NameDiagnostics.All()
+	c.ResolveName("ghost", "S", "")
+
+	entries := NameDiagnostics.All()
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Hotel+"/"+entry.ItemType+"/"+entry.ClassName+"/"+entry.Props)
+	}
+	// Only names that fell through to the classname are recorded, once each
+	if want := []string{"us/S/ghost/", "us/I/poster/9999"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recorded %v, want %v", got, want)
+	}
+	if !entries[0].FirstSeen.Equal(first[0].FirstSeen) || entries[0].LastSeen.Before(first[0].LastSeen) {
+		t.Errorf("seen again: %+v, before %+v", entries[0], first[0])
+	}
+
+	// Names fixed since, and names of other hotels, are left out of the report
+	NameDiagnostics.record("es", "ghost", "S", "")
+	nameAliases["ghost"] = "Ghost"
+	report := c.NameReport()
+	if report.Hotel != "us" || len(report.Unresolved) != 1 || report.Unresolved[0].Props != "9999" {
+		t.Errorf("report %+v, want the unknown poster alone", report)
+	}
+}
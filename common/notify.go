@@ -0,0 +1,575 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotificationConfigFile is the notification config in ConfigDir
+const NotificationConfigFile = "notifications.json"
+
+// NotificationKind is the app event a notification is about
+type NotificationKind string
+
+const (
+	NotifyScanCompleted  NotificationKind = "scan.completed"
+	NotifyTradeCompleted NotificationKind = "trade.completed"
+	NotifyTradeDeclined  NotificationKind = "trade.declined"
+	NotifyRoomChanged    NotificationKind = "room.changed"
+)
+
+// Notification is one app event sent to the notification sinks
+type Notification struct {
+	ID      string           `json:"id"`
+	Kind    NotificationKind `json:"kind"`
+	Title   string           `json:"title"`
+	Message string           `json:"message"`
+	Time    time.Time        `json:"time"`
+	Fields  []Field          `json:"fields,omitempty"`
+}
+
+// Notifier delivers notifications to one sink. Notify returns an error when
+// the sink cannot be reached so the notification can be retried later.
+type Notifier interface {
+	Name() string
+	Notify(n Notification) error
+}
+
+// DiscordNotifier posts notifications to a Discord webhook as embeds. A
+// notification that needs several messages and fails part way is resumed
+// from the failed message when it is retried.
+type DiscordNotifier struct {
+	SinkName string
+	Webhook  *DiscordWebhook
+
+	mu   sync.Mutex
+	sent map[string]int
+}
+
+func (d *DiscordNotifier) Name() string {
+	return d.SinkName
+}
+
+func (d *DiscordNotifier) Notify(n Notification) error {
+	d.mu.Lock()
+	start := d.sent[n.ID]
+	d.mu.Unlock()
+
+	err := d.Webhook.SendFrom(start, []Embed{{
+		Title:       n.Title,
+		Description: n.Message,
+		Timestamp:   n.Time.Format(time.RFC3339),
+		Color:       notificationColor(n.Kind),
+		Fields:      n.Fields,
+		Footer:      &Footer{Text: string(n.Kind)},
+	}})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var sendErr *SendError
+	if errors.As(err, &sendErr) && sendErr.Sent > 0 {
+		if d.sent == nil {
+			d.sent = make(map[string]int)
+		}
+		d.sent[n.ID] = sendErr.Sent
+	} else if err == nil {
+		delete(d.sent, n.ID)
+	}
+	return err
+}
+
+func notificationColor(kind NotificationKind) int {
+	if kind == NotifyTradeDeclined {
+		return ColorUnavailable
+	}
+	return ColorDefault
+}
+
+// WebhookNotifier posts notifications as JSON to any HTTP endpoint
+type WebhookNotifier struct {
+	SinkName string
+	URL      string
+	Client   *http.Client
+}
+
+func (w *WebhookNotifier) Name() string {
+	return w.SinkName
+}
+
+func (w *WebhookNotifier) Notify(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultFetchTimeout}
+	}
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return &NetworkError{URL: w.URL, Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &HTTPStatusError{URL: w.URL, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// LogFileNotifier appends notifications to a text file, one per line
+type LogFileNotifier struct {
+	SinkName string
+	Path     string
+}
+
+func (l *LogFileNotifier) Name() string {
+	return l.SinkName
+}
+
+func (l *LogFileNotifier) Notify(n Notification) error {
+	if err := os.MkdirAll(filepath.Dir(l.Path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line := fmt.Sprintf("%s [%s] %s: %s", n.Time.Format(time.RFC3339), n.Kind, n.Title, n.Message)
+	for _, field := range n.Fields {
+		line += fmt.Sprintf(" %s=%q", field.Name, field.Value)
+	}
+	_, err = f.WriteString(line + "\n")
+	return err
+}
+
+// ToastNotifier shows notifications inside the app through Emit
+type ToastNotifier struct {
+	Emit func(n Notification)
+}
+
+func (t *ToastNotifier) Name() string {
+	return "toast"
+}
+
+func (t *ToastNotifier) Notify(n Notification) error {
+	t.Emit(n)
+	return nil
+}
+
+// NotificationRule sends the listed event kinds, or every kind for "*", to
+// the listed sinks
+type NotificationRule struct {
+	Events []NotificationKind `json:"events"`
+	Sinks  []string           `json:"sinks"`
+}
+
+func (r NotificationRule) matches(kind NotificationKind) bool {
+	for _, event := range r.Events {
+		if event == kind || event == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationSink configures a Discord, webhook or log sink
+type NotificationSink struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// NotificationConfig is the content of notifications.json
+type NotificationConfig struct {
+	Sinks []NotificationSink `json:"sinks"`
+	Rules []NotificationRule `json:"rules"`
+}
+
+// DefaultNotificationConfig shows every event as a toast
+var DefaultNotificationConfig = NotificationConfig{
+	Rules: []NotificationRule{{Events: []NotificationKind{"*"}, Sinks: []string{"toast"}}},
+}
+
+// LoadNotificationConfig reads notifications.json from ConfigDir, falling
+// back to DefaultNotificationConfig when there is none
+func LoadNotificationConfig() (NotificationConfig, error) {
+	config := DefaultNotificationConfig
+	data, err := ioutil.ReadFile(filepath.Join(ConfigDir(), NotificationConfigFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return DefaultNotificationConfig, fmt.Errorf("%s: %w", NotificationConfigFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return DefaultNotificationConfig, err
+	}
+	return config, nil
+}
+
+// NewNotifier creates the notifier a sink config describes
+func NewNotifier(sink NotificationSink) (Notifier, error) {
+	switch sink.Type {
+	case "discord":
+		return &DiscordNotifier{SinkName: sink.Name, Webhook: NewDiscordWebhook(sink.URL)}, nil
+	case "webhook":
+		return &WebhookNotifier{SinkName: sink.Name, URL: sink.URL}, nil
+	case "log":
+		path := sink.Path
+		if path == "" {
+			path = filepath.Join(ConfigDir(), "notifications.log")
+		}
+		return &LogFileNotifier{SinkName: sink.Name, Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification sink type %q", sink.Type)
+	}
+}
+
+// Retry delays for undelivered notifications. The delay doubles with every
+// failed attempt up to maxOutboxDelay.
+const (
+	minOutboxDelay = 30 * time.Second
+	maxOutboxDelay = time.Hour
+	maxOutboxSize  = 500
+)
+
+// sinkQueueSize is how many notifications can wait for a sink's worker
+// before Send blocks
+const sinkQueueSize = 100
+
+// PendingNotification is a notification a sink has not accepted yet
+type PendingNotification struct {
+	Sink         string       `json:"sink"`
+	Notification Notification `json:"notification"`
+	Attempts     int          `json:"attempts"`
+	LastError    string       `json:"lastError"`
+	NextAttempt  time.Time    `json:"nextAttempt"`
+}
+
+// Notifications routes app events to sinks. Notifications a sink fails to
+// deliver, or that are routed to a sink that is not registered, are kept in
+// an outbox on disk and retried, oldest first. While a sink has notifications
+// queued, new ones are queued behind them so they are delivered in order.
+type Notifications struct {
+	OutboxPath string
+
+	mu      sync.Mutex
+	sinks   map[string]Notifier
+	queues  map[string]chan Notification
+	sending map[string]*sync.Mutex
+	rules   []NotificationRule
+	outbox  []PendingNotification
+	nextID  int
+	started bool
+}
+
+// Notify is the notification router used by the app
+var Notify = NewNotifications(filepath.Join(defaultCacheDir(), "outbox.json"))
+
+func NewNotifications(outboxPath string) *Notifications {
+	n := &Notifications{
+		OutboxPath: outboxPath,
+		sinks:      make(map[string]Notifier),
+		rules:      DefaultNotificationConfig.Rules,
+	}
+	n.loadOutbox()
+	return n
+}
+
+// Register adds a sink, replacing any sink of the same name
+func (n *Notifications) Register(notifier Notifier) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sinks[notifier.Name()] = notifier
+}
+
+// Configure registers the sinks of config and replaces the routing rules.
+// Sinks registered in code, such as the toast sink, are kept.
+func (n *Notifications) Configure(config NotificationConfig) error {
+	var errs []string
+	for _, sink := range config.Sinks {
+		notifier, err := NewNotifier(sink)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		n.Register(notifier)
+	}
+
+	n.mu.Lock()
+	n.rules = config.Rules
+	n.mu.Unlock()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("notifications: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Send delivers a notification to every sink a rule routes its kind to. Each
+// sink has one worker, so a sink gets notifications in the order they were
+// sent.
+func (n *Notifications) Send(kind NotificationKind, title string, message string, fields ...Field) {
+	n.mu.Lock()
+	n.nextID++
+	notification := Notification{
+		ID:      strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(n.nextID),
+		Kind:    kind,
+		Title:   title,
+		Message: message,
+		Time:    time.Now(),
+		Fields:  fields,
+	}
+	targets := make(map[string]bool)
+	for _, rule := range n.rules {
+		if rule.matches(kind) {
+			for _, sink := range rule.Sinks {
+				targets[sink] = true
+			}
+		}
+	}
+	n.mu.Unlock()
+
+	for _, sink := range sortedKeys(targets) {
+		n.sinkQueue(sink) <- notification
+	}
+}
+
+// sinkQueue returns the queue of notifications waiting for sinkName, starting
+// the worker that delivers them in order on first use
+func (n *Notifications) sinkQueue(sinkName string) chan<- Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.queues == nil {
+		n.queues = make(map[string]chan Notification)
+	}
+	queue, ok := n.queues[sinkName]
+	if !ok {
+		queue = make(chan Notification, sinkQueueSize)
+		n.queues[sinkName] = queue
+		go func() {
+			for notification := range queue {
+				n.deliver(sinkName, notification)
+			}
+		}()
+	}
+	return queue
+}
+
+func (n *Notifications) deliver(sinkName string, notification Notification) {
+	lock := n.sinkLock(sinkName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	pending := PendingNotification{Sink: sinkName, Notification: notification}
+	n.mu.Lock()
+	sink, ok := n.sinks[sinkName]
+	queued := n.queued(sinkName)
+	if ok && queued {
+		n.outbox = append(n.outbox, pending)
+	}
+	n.mu.Unlock()
+
+	if !ok {
+		err := fmt.Errorf("no sink named %s", sinkName)
+		log.Printf("notifications: %v", err)
+		n.enqueue(pending, err)
+		return
+	}
+	if queued {
+		n.saveOutbox()
+		n.flush(sinkName, true)
+		return
+	}
+	if err := sink.Notify(notification); err != nil {
+		if rejected(err) {
+			log.Printf("notifications: %s: dropping %s: %v", sinkName, notification.ID, err)
+			return
+		}
+		log.Printf("notifications: %s: %v", sinkName, err)
+		n.enqueue(pending, err)
+	}
+}
+
+// sinkLock returns the lock that keeps deliveries to one sink in order
+func (n *Notifications) sinkLock(sinkName string) *sync.Mutex {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sending == nil {
+		n.sending = make(map[string]*sync.Mutex)
+	}
+	lock, ok := n.sending[sinkName]
+	if !ok {
+		lock = &sync.Mutex{}
+		n.sending[sinkName] = lock
+	}
+	return lock
+}
+
+// queued reports whether sinkName has notifications in the outbox. n.mu must
+// be held.
+func (n *Notifications) queued(sinkName string) bool {
+	for _, pending := range n.outbox {
+		if pending.Sink == sinkName {
+			return true
+		}
+	}
+	return false
+}
+
+// rejected reports whether a sink refused a notification in a way retrying
+// will not fix, such as a 4xx response other than 429
+func rejected(err error) bool {
+	var statusErr *HTTPStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusTooManyRequests
+}
+
+// failed records a failed delivery and schedules the next attempt
+func (p *PendingNotification) failed(err error, now time.Time) {
+	p.Attempts++
+	p.LastError = err.Error()
+	delay := minOutboxDelay << (p.Attempts - 1)
+	if delay > maxOutboxDelay || delay <= 0 {
+		delay = maxOutboxDelay
+	}
+	p.NextAttempt = now.Add(delay)
+}
+
+func (n *Notifications) enqueue(pending PendingNotification, err error) {
+	pending.failed(err, time.Now())
+
+	n.mu.Lock()
+	n.outbox = append(n.outbox, pending)
+	if len(n.outbox) > maxOutboxSize {
+		n.outbox = n.outbox[len(n.outbox)-maxOutboxSize:]
+	}
+	n.mu.Unlock()
+	n.saveOutbox()
+}
+
+// RetryPending retries the undelivered notifications of every sink whose
+// oldest notification's retry delay has passed
+func (n *Notifications) RetryPending() {
+	n.mu.Lock()
+	seen := make(map[string]bool)
+	var due []string
+	now := time.Now()
+	for _, pending := range n.outbox {
+		if !seen[pending.Sink] {
+			seen[pending.Sink] = true
+			if !now.Before(pending.NextAttempt) {
+				due = append(due, pending.Sink)
+			}
+		}
+	}
+	n.mu.Unlock()
+
+	for _, sinkName := range due {
+		lock := n.sinkLock(sinkName)
+		lock.Lock()
+		n.flush(sinkName, false)
+		lock.Unlock()
+	}
+}
+
+// flush redelivers the pending notifications of sinkName in order, if its
+// oldest one is due or force is set. Delivery stops at the first failure so
+// the rest stay queued behind it; rejected notifications are dropped. The
+// sink's lock must be held.
+func (n *Notifications) flush(sinkName string, force bool) {
+	n.mu.Lock()
+	var due []PendingNotification
+	var kept []PendingNotification
+	for _, pending := range n.outbox {
+		if pending.Sink == sinkName {
+			due = append(due, pending)
+		} else {
+			kept = append(kept, pending)
+		}
+	}
+	now := time.Now()
+	sink, ok := n.sinks[sinkName]
+	if len(due) == 0 || !ok || (!force && now.Before(due[0].NextAttempt)) {
+		n.mu.Unlock()
+		return
+	}
+	n.outbox = kept
+	n.mu.Unlock()
+
+	for len(due) > 0 {
+		err := sink.Notify(due[0].Notification)
+		if err != nil && rejected(err) {
+			log.Printf("notifications: %s: dropping %s: %v", sinkName, due[0].Notification.ID, err)
+		} else if err != nil {
+			due[0].failed(err, now)
+			break
+		}
+		due = due[1:]
+	}
+
+	n.mu.Lock()
+	n.outbox = append(due, n.outbox...)
+	n.mu.Unlock()
+	n.saveOutbox()
+}
+
+// Pending returns the notifications waiting to be delivered
+func (n *Notifications) Pending() []PendingNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]PendingNotification{}, n.outbox...)
+}
+
+// Start retries the outbox every interval in the background
+func (n *Notifications) Start(interval time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.started {
+		return
+	}
+	n.started = true
+	go func() {
+		for range time.Tick(interval) {
+			n.RetryPending()
+		}
+	}()
+}
+
+func (n *Notifications) loadOutbox() {
+	data, err := ioutil.ReadFile(n.OutboxPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("notifications: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &n.outbox); err != nil {
+		log.Printf("notifications: ignoring outbox: %v", err)
+	}
+}
+
+func (n *Notifications) saveOutbox() {
+	n.mu.Lock()
+	data, err := json.MarshalIndent(n.outbox, "", "  ")
+	n.mu.Unlock()
+	if err != nil {
+		log.Printf("notifications: %v", err)
+		return
+	}
+	if err := writeFileAtomic(n.OutboxPath, data); err != nil {
+		log.Printf("notifications: failed to save outbox: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingSink records the titles it delivers and fails while down. seen is
+// called with every notification it is given.
+type recordingSink struct {
+	name string
+	seen func(n Notification)
+	mu   sync.Mutex
+	down bool
+	got  []string
+}
+
+func (r *recordingSink) Name() string {
+	return r.name
+}
+
+func (r *recordingSink) Notify(n Notification) error {
+	if r.seen != nil {
+		r.seen(n)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		return errors.New("sink down")
+	}
+	r.got = append(r.got, n.Title)
+	return nil
+}
+
+func newTestNotifications(t *testing.T) *Notifications {
+	t.Helper()
+	return NewNotifications(filepath.Join(t.TempDir(), "outbox.json"))
+}
+
+func notification(id string) Notification {
+	return Notification{ID: id, Kind: NotifyTradeCompleted, Title: id}
+}
+
+func pendingTitles(n *Notifications) string {
+	var titles []string
+	for _, pending := range n.Pending() {
+		titles = append(titles, pending.Notification.Title)
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestNotificationsKeepOrderWhileQueued(t *testing.T) {
+	n := newTestNotifications(t)
+	sink := &recordingSink{name: "sink", down: true}
+	n.Register(sink)
+
+	n.deliver("sink", notification("one"))
+	n.deliver("sink", notification("two"))
+	if got := pendingTitles(n); got != "one,two" {
+		t.Fatalf("pending %q", got)
+	}
+
+	sink.down = false
+	n.RetryPending()
+	if got := pendingTitles(n); got != "one,two" {
+		t.Fatalf("retried before the delay passed, pending %q", got)
+	}
+
+	n.deliver("sink", notification("three"))
+	if got := strings.Join(sink.got, ","); got != "one,two,three" {
+		t.Errorf("delivered %q, want one,two,three", got)
+	}
+	if len(n.Pending()) != 0 {
+		t.Errorf("pending %q", pendingTitles(n))
+	}
+}
+
+func TestNotificationsSendInOrder(t *testing.T) {
+	n := newTestNotifications(t)
+	sink := &recordingSink{name: "sink"}
+	n.Register(sink)
+	n.Configure(NotificationConfig{Rules: []NotificationRule{{Events: []NotificationKind{"*"}, Sinks: []string{"sink"}}}})
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		title := fmt.Sprint(i)
+		want = append(want, title)
+		n.Send(NotifyTradeCompleted, title, "")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sink.mu.Lock()
+		got := strings.Join(sink.got, ",")
+		sink.mu.Unlock()
+		if got == strings.Join(want, ",") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %q", got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNotificationsSaveQueued(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	n := NewNotifications(path)
+	var onDisk []string
+	sink := &recordingSink{name: "sink", down: true, seen: func(Notification) {
+		onDisk = append(onDisk, pendingTitles(NewNotifications(path)))
+	}}
+	n.Register(sink)
+
+	n.deliver("sink", notification("one"))
+	sink.down = false
+	n.deliver("sink", notification("two"))
+
+	// Two was saved behind one before one was retried, and both stay saved
+	// until the sink took them
+	if want := []string{"", "one,two", "one,two"}; strings.Join(onDisk, "|") != strings.Join(want, "|") {
+		t.Errorf("outbox on disk while delivering: %q, want %q", onDisk, want)
+	}
+	if got := pendingTitles(NewNotifications(path)); got != "" {
+		t.Errorf("outbox on disk after delivery: %q", got)
+	}
+}
+
+func TestNotificationsQueueBehindFailure(t *testing.T) {
+	n := newTestNotifications(t)
+	sink := &recordingSink{name: "sink", down: true}
+	n.Register(sink)
+
+	n.deliver("sink", notification("one"))
+	n.deliver("sink", notification("two"))
+	pending := n.Pending()
+	if len(pending) != 2 || pending[0].Attempts != 2 || pending[1].Attempts != 0 {
+		t.Fatalf("want two tried once more and one queued behind it, got %+v", pending)
+	}
+}
+
+func TestNotificationsUnknownSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	n := NewNotifications(path)
+
+	n.deliver("later", notification("one"))
+	if got := pendingTitles(n); got != "one" {
+		t.Fatalf("notification for an unregistered sink was not kept: %q", got)
+	}
+	if got := pendingTitles(NewNotifications(path)); got != "one" {
+		t.Fatalf("outbox not persisted: %q", got)
+	}
+
+	sink := &recordingSink{name: "later"}
+	n.Register(sink)
+	n.mu.Lock()
+	n.outbox[0].NextAttempt = n.outbox[0].NextAttempt.Add(-maxOutboxDelay)
+	n.mu.Unlock()
+	n.RetryPending()
+	if len(sink.got) != 1 || len(n.Pending()) != 0 {
+		t.Errorf("delivered %v, pending %q", sink.got, pendingTitles(n))
+	}
+}
+
+func TestNotificationsDropRejected(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusBadRequest)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	n := newTestNotifications(t)
+	n.Register(&WebhookNotifier{SinkName: "hook", URL: srv.URL})
+
+	n.deliver("hook", notification("bad"))
+	if len(n.Pending()) != 0 {
+		t.Errorf("400 response was queued for retry: %q", pendingTitles(n))
+	}
+
+	status.Store(http.StatusTooManyRequests)
+	n.deliver("hook", notification("limited"))
+	if got := pendingTitles(n); got != "limited" {
+		t.Errorf("429 response was not queued: %q", got)
+	}
+
+	status.Store(http.StatusNotFound)
+	n.deliver("hook", notification("gone"))
+	if len(n.Pending()) != 0 {
+		t.Errorf("rejected notifications kept: %q", pendingTitles(n))
+	}
+}
@@ -0,0 +1,263 @@
+package common
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PriceMapFile is the name of the user's price map in the config directory.
+// Its entries are merged over the built-in data/pricemap.json.
+const PriceMapFile = "pricemap.json"
+
+//go:embed data/pricemap.json
+var builtinPriceMap []byte
+
+// PriceMap joins furnidata entries to traderclub items. Classes maps a
+// classname (poster_<id> for posters) to an APIItem slug or exact API name,
+// so prices do not depend on the hotel's language. Aliases map a display name
+// to an API name and are only used for items that have no class mapping.
+type PriceMap struct {
+	Classes map[string]string `json:"classes"`
+	Aliases map[string]string `json:"aliases"`
+}
+
+var defaultPriceMap = loadDefaultPriceMap()
+
+func loadDefaultPriceMap() *PriceMap {
+	priceMap, err := ParsePriceMap(builtinPriceMap)
+	if err != nil {
+		log.Printf("pricemap: built-in price map is invalid: %v", err)
+		priceMap = &PriceMap{
+			Classes: make(map[string]string),
+			Aliases: make(map[string]string),
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(ConfigDir(), PriceMapFile))
+	if err == nil {
+		user, err := ParsePriceMap(data)
+		if err != nil {
+			log.Printf("pricemap: ignoring %s: %v", PriceMapFile, err)
+		} else {
+			priceMap.Merge(user)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("pricemap: %v", err)
+	}
+
+	return priceMap
+}
+
+func ParsePriceMap(data []byte) (*PriceMap, error) {
+	var priceMap PriceMap
+	if err := json.Unmarshal(data, &priceMap); err != nil {
+		return nil, err
+	}
+	if priceMap.Classes == nil {
+		priceMap.Classes = make(map[string]string)
+	}
+	if priceMap.Aliases == nil {
+		priceMap.Aliases = make(map[string]string)
+	}
+	return &priceMap, nil
+}
+
+// Merge copies the entries of other over the entries of m
+func (m *PriceMap) Merge(other *PriceMap) {
+	for class, slug := range other.Classes {
+		m.Classes[class] = slug
+	}
+	for name, apiName := range other.Aliases {
+		m.Aliases[name] = apiName
+	}
+}
+
+// APIPriceProvider prices items from the traderclub item list. Items are
+// matched by classname through the price map first, and only fall back to
+// their display name when no mapping matches.
+type APIPriceProvider struct {
+	priceMap *PriceMap
+	bySlug   map[string]APIItem
+	byName   map[string]APIItem
+}
+
+func NewAPIPriceProvider(items map[string]APIItem, priceMap *PriceMap) *APIPriceProvider {
+	p := &APIPriceProvider{
+		priceMap: priceMap,
+		bySlug:   make(map[string]APIItem),
+		byName:   make(map[string]APIItem),
+	}
+	for _, item := range items {
+		if item.Slug != "" {
+			p.bySlug[item.Slug] = item
+		}
+		p.byName[normalizePriceKey(item.Name)] = item
+	}
+	// Class mappings are written as slugs, so also find items whose listed
+	// slug differs from the slug of their name
+	for _, item := range items {
+		if slug := Slugify(item.Name); slug != "" {
+			if _, ok := p.bySlug[slug]; !ok {
+				p.bySlug[slug] = item
+			}
+		}
+	}
+	return p
+}
+
+func (p *APIPriceProvider) Name() string {
+	return SourceTraderClub
+}
+
+func (p *APIPriceProvider) Price(key PriceKey) (HC, bool) {
+	item, _, ok := p.match(key)
+	if !ok {
+		return 0, false
+	}
+	return item.HCVal, true
+}
+
+// Price map match kinds reported by APIPriceProvider.match
+const (
+	MatchClass = "class"
+	MatchName  = "name"
+)
+
+func (p *APIPriceProvider) match(key PriceKey) (APIItem, string, bool) {
+	for _, class := range []string{key.Key(), key.BaseKey()} {
+		if ref, ok := p.priceMap.Classes[class]; ok {
+			if item, ok := p.lookup(ref); ok {
+				return item, MatchClass, true
+			}
+		}
+	}
+
+	name := key.Name
+	if apiName, ok := p.priceMap.Aliases[name]; ok {
+		name = apiName
+	}
+	if item, ok := p.lookup(name); ok {
+		return item, MatchName, true
+	}
+	if item, ok := p.bySlug[Slugify(name)]; ok {
+		return item, MatchName, true
+	}
+	return APIItem{}, "", false
+}
+
+// lookup finds an API item by slug or exact name
+func (p *APIPriceProvider) lookup(ref string) (APIItem, bool) {
+	if item, ok := p.bySlug[ref]; ok {
+		return item, true
+	}
+	item, ok := p.byName[normalizePriceKey(ref)]
+	return item, ok
+}
+
+// Slugify turns a display name into the slug format used by traderclub
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// PriceMapEntry is one furnidata entry in a PriceMapReport
+type PriceMapEntry struct {
+	Key  string
+	Name string
+	Slug string
+}
+
+// PriceMapReport shows how well the price map covers the catalog. NameMatched
+// entries are only priced through their display name and should be added to
+// the price map; BrokenMappings point at slugs the API no longer lists.
+type PriceMapReport struct {
+	ClassMatched   []PriceMapEntry
+	NameMatched    []PriceMapEntry
+	Unmatched      []PriceMapEntry
+	BrokenMappings []PriceMapEntry
+	UnusedAPIItems []string
+}
+
+// PriceMapReport checks every furni and poster in the catalog against the
+// traderclub item list
+func (c *Catalog) PriceMapReport() PriceMapReport {
+	var report PriceMapReport
+	if c.apiPrices == nil {
+		return report
+	}
+
+	var keys []PriceKey
+	for class, furni := range c.furniData {
+		if furni.WallItem {
+			continue
+		}
+		keys = append(keys, PriceKey{ClassName: class, ItemType: "S"})
+	}
+	for key := range c.externalTexts {
+		if props, ok := posterID(key); ok {
+			keys = append(keys, PriceKey{ClassName: "poster", ItemType: "I", Props: props})
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, key := range keys {
+		key.Name = c.resolveName(key.ClassName, key.ItemType, key.Props).Text
+		item, match, ok := c.apiPrices.match(key)
+		entry := PriceMapEntry{Key: key.Key(), Name: key.Name, Slug: item.Slug}
+		if ref, mapped := c.apiPrices.priceMap.Classes[key.Key()]; mapped {
+			if _, found := c.apiPrices.lookup(ref); !found {
+				report.BrokenMappings = append(report.BrokenMappings, PriceMapEntry{Key: key.Key(), Name: key.Name, Slug: ref})
+			}
+		}
+		switch {
+		case ok && match == MatchClass:
+			report.ClassMatched = append(report.ClassMatched, entry)
+		case ok:
+			report.NameMatched = append(report.NameMatched, entry)
+		default:
+			report.Unmatched = append(report.Unmatched, entry)
+		}
+		if ok {
+			used[item.Name] = true
+		}
+	}
+
+	for _, item := range c.apiItems {
+		if !used[item.Name] {
+			report.UnusedAPIItems = append(report.UnusedAPIItems, fmt.Sprintf("%s (%s)", item.Name, item.Slug))
+		}
+	}
+
+	for _, entries := range [][]PriceMapEntry{report.ClassMatched, report.NameMatched, report.Unmatched, report.BrokenMappings} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	}
+	sort.Strings(report.UnusedAPIItems)
+	return report
+}
+
+// posterID returns the poster id of a poster_<id>_name text key
+func posterID(key string) (string, bool) {
+	if !strings.HasPrefix(key, "poster_") || !strings.HasSuffix(key, "_name") {
+		return "", false
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(key, "poster_"), "_name")
+	return id, id != ""
+}
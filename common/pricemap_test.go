@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+func TestBuiltinPriceMap(t *testing.T) {
+	priceMap, err := ParsePriceMap(builtinPriceMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for class, slug := range priceMap.Classes {
+		if slug == "" || Slugify(slug) != slug {
+			t.Errorf("%s maps to %q, want a slug", class, slug)
+		}
+	}
+}
+
+func TestPriceByClassAcrossLanguages(t *testing.T) {
+	items := []APIItem{
+		{Name: "Throne", Slug: "throne", HCVal: 5000},
+		{Name: "Club Sofa", Slug: "club-sofa-1", HCVal: 1200},
+	}
+	furni := []FurniData{
+		{ClassName: "throne", Name: "Thron"},
+		{ClassName: "hcsohva", Name: "Clubsofa"},
+		{ClassName: "chair", Name: "Stuhl"},
+	}
+	texts := map[string]string{
+		"furni_throne_name":  "Thron",
+		"furni_hcsohva_name": "Clubsofa",
+	}
+	c, err := NewCatalog(NewMemorySource(furni, texts, items))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for class, want := range map[string]HC{"throne": 5000, "hcsohva": 1200, "chair": 0} {
+		got := c.EnrichInventoryItem(inventory.Item{Class: class, Type: "S"})
+		if got.HCValue != want {
+			t.Errorf("%s (%s): got %s, want %s", class, got.Name, got.HCValue, want)
+		}
+	}
+
+	report := c.PriceMapReport()
+	matched := make(map[string]bool)
+	for _, entry := range report.ClassMatched {
+		matched[entry.Key] = true
+	}
+	if !matched["throne"] || !matched["hcsohva"] || len(report.NameMatched) != 0 {
+		t.Errorf("class matched %v, name matched %v", report.ClassMatched, report.NameMatched)
+	}
+}
@@ -0,0 +1,417 @@
+package common
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	SourceTraderClub = "traderclub"
+	SourceSheet      = "sheet"
+	SourceOverride   = "override"
+)
+
+// PriceKey identifies the item being priced
+type PriceKey struct {
+	ClassName string
+	ItemType  string
+	Props     string
+	Name      string
+}
+
+// Key returns the classname based key used by price sheets and overrides.
+// Posters are keyed by their props since they share one class.
+func (k PriceKey) Key() string {
+	if k.ItemType == "I" && k.Props != "" {
+		return fmt.Sprintf("poster_%s", k.Props)
+	}
+	return k.ClassName
+}
+
+// BaseKey returns the key of the base furni for colour variants, so a price
+// set for the base is inherited by every variant without a price of its own.
+// It returns Key() for other items.
+func (k PriceKey) BaseKey() string {
+	return BaseClass(k.Key())
+}
+
+// Price is a value together with the source that produced it
+type Price struct {
+	Value  HC
+	Source string
+}
+
+// PriceProvider looks up the HC value of an item
+type PriceProvider interface {
+	Name() string
+	Price(key PriceKey) (HC, bool)
+}
+
+// MergeStrategy decides how the prices of several providers are combined
+type MergeStrategy string
+
+const (
+	MergeFirst   MergeStrategy = "first"
+	MergeHighest MergeStrategy = "highest"
+	MergeLowest  MergeStrategy = "lowest"
+	MergeAverage MergeStrategy = "average"
+)
+
+// PriceChain combines providers. With MergeFirst the first provider that
+// knows the item wins, the other strategies consult every provider.
+type PriceChain struct {
+	Providers []PriceProvider
+	Strategy  MergeStrategy
+}
+
+func (p *PriceChain) Price(key PriceKey) (Price, bool) {
+	var found []Price
+	for _, provider := range p.Providers {
+		value, ok := provider.Price(key)
+		if !ok {
+			continue
+		}
+		if p.Strategy == MergeFirst || p.Strategy == "" {
+			return Price{Value: value, Source: provider.Name()}, true
+		}
+		found = append(found, Price{Value: value, Source: provider.Name()})
+	}
+	if len(found) == 0 {
+		return Price{}, false
+	}
+
+	switch p.Strategy {
+	case MergeHighest:
+		best := found[0]
+		for _, price := range found[1:] {
+			if price.Value > best.Value {
+				best = price
+			}
+		}
+		return best, true
+	case MergeLowest:
+		best := found[0]
+		for _, price := range found[1:] {
+			if price.Value < best.Value {
+				best = price
+			}
+		}
+		return best, true
+	case MergeAverage:
+		var total HC
+		sources := make([]string, 0, len(found))
+		for _, price := range found {
+			total += price.Value
+			sources = append(sources, price.Source)
+		}
+		return Price{
+			Value:  total.Div(len(found)),
+			Source: fmt.Sprintf("average(%s)", strings.Join(sources, ", ")),
+		}, true
+	}
+
+	return found[0], true
+}
+
+// PricingConfig controls which providers are consulted and in which order
+type PricingConfig struct {
+	Order    []string      `json:"order"`
+	Strategy MergeStrategy `json:"strategy"`
+	Sheets   []string      `json:"sheets"`
+}
+
+var DefaultPricingConfig = PricingConfig{
+	Order:    []string{SourceOverride, SourceSheet, SourceTraderClub},
+	Strategy: MergeFirst,
+}
+
+var (
+	pricingConfig  = DefaultPricingConfig
+	priceSheets    []PriceProvider
+	priceOverrides = NewOverridePriceProvider(filepath.Join(ConfigDir(), "price_overrides.json"))
+	pricingMu      sync.RWMutex
+)
+
+// LoadPricingConfig reads pricing.json from the config directory, loads the
+// price sheets it lists and the user's overrides
+func LoadPricingConfig() error {
+	config := DefaultPricingConfig
+	data, err := ioutil.ReadFile(filepath.Join(ConfigDir(), "pricing.json"))
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("pricing.json: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := priceOverrides.Load(); err != nil {
+		return err
+	}
+	return SetPricingConfig(config)
+}
+
+// SetPricingConfig replaces the pricing configuration and reloads the price
+// sheets it lists
+func SetPricingConfig(config PricingConfig) error {
+	var sheets []PriceProvider
+	for _, path := range config.Sheets {
+		sheet, err := LoadPriceSheet(path)
+		if err != nil {
+			return err
+		}
+		sheets = append(sheets, sheet)
+	}
+
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	pricingConfig = config
+	priceSheets = sheets
+	return nil
+}
+
+// PriceOverrides returns the user's manual price overrides
+func PriceOverrides() *OverridePriceProvider {
+	return priceOverrides
+}
+
+// PriceChain returns the configured providers for this catalog, ordered by
+// the pricing configuration
+func (c *Catalog) PriceChain() *PriceChain {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+
+	bySource := map[string][]PriceProvider{
+		SourceOverride: {priceOverrides},
+		SourceSheet:    priceSheets,
+	}
+	if c.apiPrices != nil {
+		bySource[SourceTraderClub] = []PriceProvider{c.apiPrices}
+	}
+
+	chain := &PriceChain{Strategy: pricingConfig.Strategy}
+	for _, source := range pricingConfig.Order {
+		chain.Providers = append(chain.Providers, bySource[source]...)
+		delete(bySource, source)
+	}
+	return chain
+}
+
+// Price values an item using the configured providers
+func (c *Catalog) Price(key PriceKey) (Price, bool) {
+	return c.PriceChain().Price(key)
+}
+
+// SheetPriceProvider prices items from a local CSV or JSON price sheet. Rows
+// are keyed by classname (poster_<id> for posters) or by display name.
+type SheetPriceProvider struct {
+	path   string
+	prices map[string]HC
+}
+
+// LoadPriceSheet reads a price sheet. CSV sheets have "key,value" rows with an
+// optional header, JSON sheets are an object of key to value.
+func LoadPriceSheet(path string) (*SheetPriceProvider, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]HC)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var raw map[string]HC
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("price sheet %s: %w", path, err)
+		}
+		for key, value := range raw {
+			prices[normalizePriceKey(key)] = value
+		}
+	} else {
+		reader := csv.NewReader(strings.NewReader(string(data)))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("price sheet %s: %w", path, err)
+		}
+		for i, record := range records {
+			if len(record) < 2 {
+				continue
+			}
+			value, err := ParseHC(record[1])
+			if err != nil {
+				if i == 0 {
+					continue // header
+				}
+				return nil, fmt.Errorf("price sheet %s line %d: %w", path, i+1, err)
+			}
+			prices[normalizePriceKey(record[0])] = value
+		}
+	}
+
+	return &SheetPriceProvider{path: path, prices: prices}, nil
+}
+
+func (p *SheetPriceProvider) Name() string {
+	return SourceSheet
+}
+
+func (p *SheetPriceProvider) Price(key PriceKey) (HC, bool) {
+	return lookupPrice(p.prices, key)
+}
+
+// OverridePriceProvider holds prices the user set by hand, persisted as JSON
+type OverridePriceProvider struct {
+	path   string
+	prices map[string]HC
+	mu     sync.RWMutex
+}
+
+func NewOverridePriceProvider(path string) *OverridePriceProvider {
+	return &OverridePriceProvider{
+		path:   path,
+		prices: make(map[string]HC),
+	}
+}
+
+func (p *OverridePriceProvider) Name() string {
+	return SourceOverride
+}
+
+func (p *OverridePriceProvider) Price(key PriceKey) (HC, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return lookupPrice(p.prices, key)
+}
+
+// Load replaces the overrides with the ones saved on disk
+func (p *OverridePriceProvider) Load() error {
+	data, err := ioutil.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var prices map[string]HC
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return fmt.Errorf("price overrides: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prices = make(map[string]HC)
+	for key, value := range prices {
+		p.prices[normalizePriceKey(key)] = value
+	}
+	return nil
+}
+
+// Set overrides the price of the item with the given classname based key or
+// display name and saves the overrides
+func (p *OverridePriceProvider) Set(key string, value HC) error {
+	p.mu.Lock()
+	p.prices[normalizePriceKey(key)] = value
+	p.mu.Unlock()
+	return p.save()
+}
+
+// Remove deletes an override and saves the overrides
+func (p *OverridePriceProvider) Remove(key string) error {
+	p.mu.Lock()
+	delete(p.prices, normalizePriceKey(key))
+	p.mu.Unlock()
+	return p.save()
+}
+
+// All returns a copy of the current overrides
+func (p *OverridePriceProvider) All() map[string]HC {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result := make(map[string]HC, len(p.prices))
+	for key, value := range p.prices {
+		result[key] = value
+	}
+	return result
+}
+
+func (p *OverridePriceProvider) save() error {
+	data, err := json.MarshalIndent(p.All(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path, data)
+}
+
+func normalizePriceKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+func lookupPrice(prices map[string]HC, key PriceKey) (HC, bool) {
+	for _, k := range []string{key.Key(), key.BaseKey(), key.Name} {
+		if k == "" {
+			continue
+		}
+		if value, ok := prices[normalizePriceKey(k)]; ok {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// Valuation is the value of a set of items and where each price came from
+type Valuation struct {
+	Total   HC
+	Items   []ValuedItem
+	Sources []string
+}
+
+// ValuedItem is one priced item of a Valuation
+type ValuedItem struct {
+	ItemId      int
+	Name        string
+	HCValue     HC
+	PriceSource string
+	IconURL     string
+	Rare        bool
+}
+
+// ValueItems values a list of enriched items, for example one side of a trade
+func ValueItems(items []EnrichedInventoryItem) Valuation {
+	var valuation Valuation
+	sources := make(map[string]bool)
+	c := DefaultCatalog()
+	for _, item := range items {
+		valuation.Total += item.HCValue
+		valuation.Items = append(valuation.Items, ValuedItem{
+			ItemId:      item.ItemId,
+			Name:        item.Name,
+			HCValue:     item.HCValue,
+			PriceSource: item.PriceSource,
+			IconURL:     c.RemoteIconURL(item.Class, string(item.Type), item.Props),
+			Rare:        item.Rare,
+		})
+		if item.PriceSource != "" {
+			sources[item.PriceSource] = true
+		}
+	}
+	valuation.Sources = sortedKeys(sources)
+	return valuation
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,266 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+// staticPrices is a provider with a fixed set of prices by key
+type staticPrices struct {
+	name   string
+	prices map[string]HC
+}
+
+func (s staticPrices) Name() string {
+	return s.name
+}
+
+func (s staticPrices) Price(key PriceKey) (HC, bool) {
+	value, ok := s.prices[key.Key()]
+	return value, ok
+}
+
+func TestPriceChainMerge(t *testing.T) {
+	providers := []PriceProvider{
+		staticPrices{"a", map[string]HC{"throne": 4000}},
+		staticPrices{"b", map[string]HC{"throne": 5000, "chair_polyfon": 25}},
+		staticPrices{"c", map[string]HC{"throne": 4500}},
+	}
+	throne := PriceKey{ClassName: "throne", ItemType: "S"}
+
+	tests := []struct {
+		strategy MergeStrategy
+		want     Price
+	}{
+		{"", Price{Value: 4000, Source: "a"}},
+		{MergeFirst, Price{Value: 4000, Source: "a"}},
+		{MergeHighest, Price{Value: 5000, Source: "b"}},
+		{MergeLowest, Price{Value: 4000, Source: "a"}},
+		{MergeAverage, Price{Value: 4500, Source: "average(a, b, c)"}},
+	}
+	for _, tt := range tests {
+		chain := &PriceChain{Providers: providers, Strategy: tt.strategy}
+		if got, ok := chain.Price(throne); !ok || got != tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.strategy, got, ok, tt.want)
+		}
+	}
+
+	// Only the providers that know the item take part
+	chain := &PriceChain{Providers: providers, Strategy: MergeAverage}
+	if got, _ := chain.Price(PriceKey{ClassName: "chair_polyfon", ItemType: "S"}); got != (Price{Value: 25, Source: "average(b)"}) {
+		t.Errorf("chair: %+v", got)
+	}
+	if got, ok := chain.Price(PriceKey{ClassName: "poster", ItemType: "I", Props: "5003"}); ok {
+		t.Errorf("unknown item priced at %+v", got)
+	}
+}
+
+// usePricing replaces the pricing configuration and the user's overrides for
+// the rest of the test
+func usePricing(t *testing.T, config PricingConfig) *OverridePriceProvider {
+	t.Helper()
+	pricingMu.RLock()
+	previousConfig, previousSheets, previousOverrides := pricingConfig, priceSheets, priceOverrides
+	pricingMu.RUnlock()
+	t.Cleanup(func() {
+		pricingMu.Lock()
+		pricingConfig, priceSheets, priceOverrides = previousConfig, previousSheets, previousOverrides
+		pricingMu.Unlock()
+	})
+
+	overrides := NewOverridePriceProvider(filepath.Join(t.TempDir(), "price_overrides.json"))
+	pricingMu.Lock()
+	priceOverrides = overrides
+	pricingMu.Unlock()
+	if err := SetPricingConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	return overrides
+}
+
+func writeSheet(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCatalogPricePriority(t *testing.T) {
+	c := loadFixtureCatalog(t)
+	sheet := writeSheet(t, "prices.csv", "throne,40\n")
+	throne := PriceKey{ClassName: "throne", ItemType: "S", Name: "Throne"}
+
+	overrides := usePricing(t, PricingConfig{
+		Order:    DefaultPricingConfig.Order,
+		Strategy: MergeFirst,
+		Sheets:   []string{sheet},
+	})
+	if err := overrides.Set("Throne", 3000); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Price(throne); got != (Price{Value: 3000, Source: SourceOverride}) {
+		t.Errorf("with an override: %+v", got)
+	}
+
+	if err := overrides.Remove("throne"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Price(throne); got != (Price{Value: 4000, Source: SourceSheet}) {
+		t.Errorf("with a sheet: %+v", got)
+	}
+
+	usePricing(t, PricingConfig{Order: []string{SourceTraderClub, SourceSheet}, Sheets: []string{sheet}})
+	if got, _ := c.Price(throne); got != (Price{Value: 5000, Source: SourceTraderClub}) {
+		t.Errorf("traderclub first: %+v", got)
+	}
+
+	// Sources left out of the order are not consulted
+	usePricing(t, PricingConfig{Order: []string{SourceSheet}})
+	if got, ok := c.Price(throne); ok {
+		t.Errorf("priced without any provider: %+v", got)
+	}
+}
+
+func TestLoadPriceSheet(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"prices.csv", "key,value\nthrone, 40\nposter_5003,3.5\nDining Chair,0.30\n"},
+		{"prices.json", `{"THRONE": 40, "poster_5003": 3.5, " dining chair ": 0.3}`},
+	}
+	for _, tt := range tests {
+		sheet, err := LoadPriceSheet(writeSheet(t, tt.name, tt.content))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, want := range []struct {
+			key   PriceKey
+			value HC
+		}{
+			{PriceKey{ClassName: "throne", ItemType: "S"}, 4000},
+			{PriceKey{ClassName: "poster", ItemType: "I", Props: "5003"}, 350},
+			// Looked up by display name
+			{PriceKey{ClassName: "chair_polyfon", ItemType: "S", Name: "Dining Chair"}, 30},
+		} {
+			if got, ok := sheet.Price(want.key); !ok || got != want.value {
+				t.Errorf("%s: %s = %s, %v, want %s", tt.name, want.key.Key(), got, ok, want.value)
+			}
+		}
+		if _, ok := sheet.Price(PriceKey{ClassName: "poster", ItemType: "I", Props: "1"}); ok {
+			t.Errorf("%s: unknown poster priced", tt.name)
+		}
+	}
+
+	if _, err := LoadPriceSheet(writeSheet(t, "bad.csv", "key,value\nthrone,forty\n")); err == nil {
+		t.Error("sheet with an invalid price loaded")
+	}
+	if _, err := LoadPriceSheet(writeSheet(t, "bad.json", `{"throne": "forty"}`)); err == nil {
+		t.Error("json sheet with an invalid price loaded")
+	}
+}
+
+func TestPriceSheetBaseKey(t *testing.T) {
+	sheet, err := LoadPriceSheet(writeSheet(t, "prices.csv", "chair_polyfon,0.25\nchair_polyfon*4,1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for class, want := range map[string]HC{
+		"chair_polyfon":   25,
+		"chair_polyfon*4": 100,
+		"chair_polyfon*7": 25,
+	} {
+		if got, ok := sheet.Price(PriceKey{ClassName: class, ItemType: "S"}); !ok || got != want {
+			t.Errorf("%s = %s, %v, want %s", class, got, ok, want)
+		}
+	}
+}
+
+func TestOverridePriceProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "price_overrides.json")
+	overrides := NewOverridePriceProvider(path)
+	if err := overrides.Load(); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+
+	if err := overrides.Set(" Throne ", 3000); err != nil {
+		t.Fatal(err)
+	}
+	if err := overrides.Set("poster_5003", 400); err != nil {
+		t.Fatal(err)
+	}
+	if err := overrides.Remove("POSTER_5003"); err != nil {
+		t.Fatal(err)
+	}
+	if got := overrides.All(); !reflect.DeepEqual(got, map[string]HC{"throne": 3000}) {
+		t.Errorf("overrides %v", got)
+	}
+
+	reloaded := NewOverridePriceProvider(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reloaded.Price(PriceKey{ClassName: "throne", ItemType: "S"}); !ok || got != 3000 {
+		t.Errorf("reloaded throne = %s, %v", got, ok)
+	}
+	if _, ok := reloaded.Price(PriceKey{ClassName: "poster", ItemType: "I", Props: "5003"}); ok {
+		t.Error("removed override was saved")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Load(); err == nil {
+		t.Error("corrupt overrides loaded")
+	}
+}
+
+func TestValueItems(t *testing.T) {
+	c := loadFixtureCatalog(t)
+	previous := DefaultCatalog()
+	SetDefaultCatalog(c)
+	t.Cleanup(func() { SetDefaultCatalog(previous) })
+	usePricing(t, DefaultPricingConfig)
+
+	throne := c.EnrichInventoryItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+	garland := c.EnrichInventoryItem(inventory.Item{ItemId: 2, Class: "poster", Type: "I", Props: "5003"})
+	garland.PriceSource = SourceOverride
+	garland.HCValue = 400
+	// The rare flag is taken from the item as enriched, not looked up again
+	garland.Rare = true
+
+	valuation := ValueItems([]EnrichedInventoryItem{throne, garland, throne})
+	if valuation.Total != 10400 {
+		t.Errorf("total %s, want 104.00", valuation.Total)
+	}
+	if want := []string{SourceOverride, SourceTraderClub}; !reflect.DeepEqual(valuation.Sources, want) {
+		t.Errorf("sources %v, want %v", valuation.Sources, want)
+	}
+	if len(valuation.Items) != 3 {
+		t.Fatalf("items %+v", valuation.Items)
+	}
+	want := ValuedItem{
+		ItemId:      2,
+		Name:        "Purple Garland",
+		HCValue:     400,
+		PriceSource: SourceOverride,
+		IconURL:     c.RemoteIconURL("poster", "I", "5003"),
+		Rare:        true,
+	}
+	if valuation.Items[1] != want {
+		t.Errorf("garland valued as %+v, want %+v", valuation.Items[1], want)
+	}
+	if !valuation.Items[0].Rare || valuation.Items[0].HCValue != 5000 {
+		t.Errorf("throne valued as %+v", valuation.Items[0])
+	}
+
+	if empty := ValueItems(nil); empty.Total != 0 || len(empty.Items) != 0 || len(empty.Sources) != 0 {
+		t.Errorf("empty valuation %+v", empty)
+	}
+}
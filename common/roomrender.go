@@ -0,0 +1,268 @@
+package common
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// Isometric tile geometry in pixels, matching the game's own projection
+const (
+	tileHalfWidth  = 32
+	tileHalfHeight = 16
+	wallHeight     = 96
+	stackHeight    = 32
+	renderPadding  = 16
+	iconHeadroom   = 64
+)
+
+var (
+	renderBackground = color.NRGBA{R: 0x1e, G: 0x1e, B: 0x24, A: 0xff}
+	renderFloor      = color.NRGBA{R: 0x98, G: 0x98, B: 0x68, A: 0xff}
+	renderFloorEdge  = color.NRGBA{R: 0x7a, G: 0x7a, B: 0x52, A: 0xff}
+	renderLeftWall   = color.NRGBA{R: 0xa8, G: 0xb4, B: 0xc0, A: 0xff}
+	renderRightWall  = color.NRGBA{R: 0xc8, G: 0xd2, B: 0xdc, A: 0xff}
+	renderFootprint  = color.NRGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x40}
+	renderMarker     = color.NRGBA{R: 0xe0, G: 0x80, B: 0x40, A: 0xff}
+)
+
+// IconLoader returns the icon image for a revision and file as found in a
+// RoomSnapshot. Items whose icon cannot be loaded are drawn as markers.
+type IconLoader func(revision string, file string) (image.Image, error)
+
+// IconCacheLoader loads icons through an IconCache
+func IconCacheLoader(icons *IconCache) IconLoader {
+	return func(revision string, file string) (image.Image, error) {
+		body, err := icons.Get(revision, file)
+		if err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(body))
+	}
+}
+
+// roomBounds is the tile area covered by a snapshot
+type roomBounds struct {
+	minX, minY, maxX, maxY int
+	maxZ                   float64
+}
+
+func snapshotBounds(snapshot RoomSnapshot) roomBounds {
+	b := roomBounds{minX: math.MaxInt32, minY: math.MaxInt32, maxX: math.MinInt32, maxY: math.MinInt32}
+	include := func(x, y int) {
+		b.minX = min(b.minX, x)
+		b.minY = min(b.minY, y)
+		b.maxX = max(b.maxX, x)
+		b.maxY = max(b.maxY, y)
+	}
+
+	for _, obj := range snapshot.Objects {
+		include(obj.X, obj.Y)
+		include(obj.X+max(obj.Width, 1)-1, obj.Y+max(obj.Height, 1)-1)
+		b.maxZ = math.Max(b.maxZ, obj.Z)
+	}
+	for _, item := range snapshot.WallItems {
+		if loc, err := ParseWallLocation(item.Location); err == nil {
+			include(loc.WallX, loc.WallY)
+		}
+	}
+
+	if b.minX > b.maxX {
+		return roomBounds{}
+	}
+	return b
+}
+
+// RenderRoom draws an isometric picture of a room snapshot: the floor and
+// walls spanned by its items, wall items on the walls and floor items on
+// their tiles, back to front
+func RenderRoom(snapshot RoomSnapshot, icons IconLoader) *image.NRGBA {
+	b := snapshotBounds(snapshot)
+
+	// Screen position of the top corner of tile (x, y) before offsetting
+	iso := func(x, y float64) image.Point {
+		return image.Pt(int(math.Round((x-y)*tileHalfWidth)), int(math.Round((x+y)*tileHalfHeight)))
+	}
+
+	left := iso(float64(b.minX), float64(b.maxY+1)).X
+	right := iso(float64(b.maxX+1), float64(b.minY)).X
+	top := iso(float64(b.minX), float64(b.minY)).Y - wallHeight - int(b.maxZ*stackHeight) - iconHeadroom
+	bottom := iso(float64(b.maxX+1), float64(b.maxY+1)).Y
+	offset := image.Pt(renderPadding-left, renderPadding-top)
+	at := func(x, y float64) image.Point {
+		return iso(x, y).Add(offset)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, right-left+2*renderPadding, bottom-top+2*renderPadding))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: renderBackground}, image.Point{}, draw.Src)
+
+	// Walls along the back edges of the floor
+	corner := at(float64(b.minX), float64(b.minY))
+	leftEnd := at(float64(b.minX), float64(b.maxY+1))
+	rightEnd := at(float64(b.maxX+1), float64(b.minY))
+	up := image.Pt(0, -wallHeight)
+	fillPolygon(img, []image.Point{leftEnd, corner, corner.Add(up), leftEnd.Add(up)}, renderLeftWall)
+	fillPolygon(img, []image.Point{corner, rightEnd, rightEnd.Add(up), corner.Add(up)}, renderRightWall)
+
+	for x := b.minX; x <= b.maxX; x++ {
+		for y := b.minY; y <= b.maxY; y++ {
+			tile := tilePolygon(at, float64(x), float64(y), 1, 1)
+			fillPolygon(img, tile, renderFloor)
+			drawPolygon(img, tile, renderFloorEdge)
+		}
+	}
+
+	for _, item := range snapshot.WallItems {
+		loc, err := ParseWallLocation(item.Location)
+		if err != nil {
+			continue
+		}
+		anchor := at(float64(loc.WallX), float64(loc.WallY)).Add(image.Pt(loc.LocalX, loc.LocalY-wallHeight))
+		if icon := loadIcon(icons, item.Revision, item.IconFile); icon != nil {
+			draw.Draw(img, icon.Bounds().Sub(icon.Bounds().Min).Add(anchor), icon, icon.Bounds().Min, draw.Over)
+		} else {
+			fillPolygon(img, markerPolygon(anchor.Add(image.Pt(0, 8))), renderMarker)
+		}
+	}
+
+	objects := append([]SnapshotObject{}, snapshot.Objects...)
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		if depthA, depthB := a.X+a.Width+a.Y+a.Height, b.X+b.Width+b.Y+b.Height; depthA != depthB {
+			return depthA < depthB
+		}
+		return a.Z < b.Z
+	})
+
+	for _, obj := range objects {
+		width, height := float64(max(obj.Width, 1)), float64(max(obj.Height, 1))
+		fillPolygon(img, tilePolygon(at, float64(obj.X), float64(obj.Y), width, height), renderFootprint)
+
+		base := at(float64(obj.X)+width/2, float64(obj.Y)+height/2).Add(image.Pt(0, -int(obj.Z*stackHeight)))
+		if icon := loadIcon(icons, obj.Revision, obj.IconFile); icon != nil {
+			size := icon.Bounds().Size()
+			dst := image.Rectangle{Min: base.Sub(image.Pt(size.X/2, size.Y)), Max: base.Add(image.Pt(size.X-size.X/2, 0))}
+			draw.Draw(img, dst, icon, icon.Bounds().Min, draw.Over)
+		} else {
+			fillPolygon(img, markerPolygon(base), renderMarker)
+		}
+	}
+
+	return img
+}
+
+// EncodeRoomPNG renders a room snapshot and writes it to w as PNG
+func EncodeRoomPNG(w io.Writer, snapshot RoomSnapshot, icons IconLoader) error {
+	return png.Encode(w, RenderRoom(snapshot, icons))
+}
+
+func loadIcon(icons IconLoader, revision string, file string) image.Image {
+	if icons == nil || file == "" {
+		return nil
+	}
+	icon, err := icons(revision, file)
+	if err != nil {
+		return nil
+	}
+	return icon
+}
+
+// tilePolygon returns the diamond covering w by h tiles from tile (x, y)
+func tilePolygon(at func(x, y float64) image.Point, x, y, w, h float64) []image.Point {
+	return []image.Point{at(x, y), at(x+w, y), at(x+w, y+h), at(x, y+h)}
+}
+
+// markerPolygon is a small diamond standing on p, drawn for items without
+// an icon
+func markerPolygon(p image.Point) []image.Point {
+	return []image.Point{p.Add(image.Pt(0, -16)), p.Add(image.Pt(8, -8)), p, p.Add(image.Pt(-8, -8))}
+}
+
+// fillPolygon fills a convex polygon by scanning each row between its
+// leftmost and rightmost crossing
+func fillPolygon(img *image.NRGBA, points []image.Point, c color.NRGBA) {
+	minY, maxY := points[0].Y, points[0].Y
+	for _, p := range points[1:] {
+		minY = min(minY, p.Y)
+		maxY = max(maxY, p.Y)
+	}
+
+	for y := minY; y < maxY; y++ {
+		fy := float64(y) + 0.5
+		x0, x1 := math.Inf(1), math.Inf(-1)
+		for i, a := range points {
+			b := points[(i+1)%len(points)]
+			if (float64(a.Y) <= fy) == (float64(b.Y) <= fy) {
+				continue
+			}
+			x := float64(a.X) + (fy-float64(a.Y))*float64(b.X-a.X)/float64(b.Y-a.Y)
+			x0 = math.Min(x0, x)
+			x1 = math.Max(x1, x)
+		}
+		for x := int(math.Round(x0)); x < int(math.Round(x1)); x++ {
+			blend(img, x, y, c)
+		}
+	}
+}
+
+func drawPolygon(img *image.NRGBA, points []image.Point, c color.NRGBA) {
+	for i, a := range points {
+		drawLine(img, a, points[(i+1)%len(points)], c)
+	}
+}
+
+func drawLine(img *image.NRGBA, a, b image.Point, c color.NRGBA) {
+	dx, dy := abs(b.X-a.X), -abs(b.Y-a.Y)
+	sx, sy := 1, 1
+	if a.X > b.X {
+		sx = -1
+	}
+	if a.Y > b.Y {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		blend(img, a.X, a.Y, c)
+		if a == b {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			a.X += sx
+		}
+		if e2 <= dx {
+			err += dx
+			a.Y += sy
+		}
+	}
+}
+
+// blend draws c over the pixel at x, y
+func blend(img *image.NRGBA, x, y int, c color.NRGBA) {
+	if !image.Pt(x, y).In(img.Rect) {
+		return
+	}
+	if c.A == 0xff {
+		img.SetNRGBA(x, y, c)
+		return
+	}
+	dst := img.NRGBAAt(x, y)
+	a := uint32(c.A)
+	mix := func(s, d uint8) uint8 {
+		return uint8((uint32(s)*a + uint32(d)*(0xff-a)) / 0xff)
+	}
+	img.SetNRGBA(x, y, color.NRGBA{R: mix(c.R, dst.R), G: mix(c.G, dst.G), B: mix(c.B, dst.B), A: 0xff})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// fixtureIcons are solid icons for the files in testdata/rooms/snapshot.json,
+// so renders do not depend on the image server
+var fixtureIcons = map[string]color.NRGBA{
+	"throne_icon.png":     {R: 0xff, G: 0xcc, B: 0x00, A: 0xff},
+	"hcsohva_icon.png":    {R: 0x20, G: 0x60, B: 0xc0, A: 0xff},
+	"edice_icon.png":      {R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff},
+	"poster5003_icon.png": {R: 0x90, G: 0x30, B: 0xa0, A: 0xff},
+}
+
+func fixtureIconLoader(revision string, file string) (image.Image, error) {
+	c, ok := fixtureIcons[file]
+	if !ok {
+		return nil, fmt.Errorf("no fixture icon %s", file)
+	}
+	icon := image.NewNRGBA(image.Rect(0, 0, 24, 32))
+	for i := 0; i < len(icon.Pix); i += 4 {
+		icon.Pix[i], icon.Pix[i+1], icon.Pix[i+2], icon.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return icon, nil
+}
+
+func loadRoomFixture(t *testing.T) RoomSnapshot {
+	t.Helper()
+	snapshot, err := LoadRoomSnapshot(filepath.Join("testdata", "rooms", "snapshot.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func countColor(img *image.NRGBA, c color.NRGBA) int {
+	n := 0
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.NRGBAAt(x, y) == c {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestRenderRoomFixture(t *testing.T) {
+	snapshot := loadRoomFixture(t)
+	if len(snapshot.Objects) != 4 || len(snapshot.WallItems) != 2 {
+		t.Fatalf("fixture has %d objects and %d wall items", len(snapshot.Objects), len(snapshot.WallItems))
+	}
+
+	img := RenderRoom(snapshot, fixtureIconLoader)
+
+	// Tiles 2..6 by 1..4 plus padding, walls and headroom for one stack level
+	if got, want := img.Bounds().Size(), image.Pt(320, 368); got != want {
+		t.Errorf("size %v, want %v", got, want)
+	}
+	if img.NRGBAAt(0, 0) != renderBackground {
+		t.Errorf("corner is %v, want the background", img.NRGBAAt(0, 0))
+	}
+	for file, c := range fixtureIcons {
+		if countColor(img, c) == 0 {
+			t.Errorf("icon %s was not drawn", file)
+		}
+	}
+	if countColor(img, renderMarker) == 0 {
+		t.Error("item without an icon was not drawn as a marker")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "rooms", "snapshot.png")
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	wantImg, err := png.Decode(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantImg.Bounds() != img.Bounds() {
+		t.Fatalf("render is %v, golden is %v", img.Bounds(), wantImg.Bounds())
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if got, want := img.NRGBAAt(x, y), color.NRGBAModel.Convert(wantImg.At(x, y)); got != want {
+				t.Fatalf("pixel (%d, %d) is %v, golden has %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRenderRoomWithoutIcons(t *testing.T) {
+	img := RenderRoom(loadRoomFixture(t), nil)
+	for file, c := range fixtureIcons {
+		if countColor(img, c) != 0 {
+			t.Errorf("icon %s drawn without a loader", file)
+		}
+	}
+	if countColor(img, renderMarker) == 0 {
+		t.Error("no markers drawn")
+	}
+}
+
+func TestRenderEmptyRoom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeRoomPNG(&buf, RoomSnapshot{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseWallLocation(t *testing.T) {
+	tests := []struct {
+		in   string
+		want WallLocation
+		ok   bool
+	}{
+		{":w=3,7 l=12,34 r", WallLocation{WallX: 3, WallY: 7, LocalX: 12, LocalY: 34, Side: 'r'}, true},
+		{" :w=0,1 l=-4,5 l ", WallLocation{WallX: 0, WallY: 1, LocalX: -4, LocalY: 5, Side: 'l'}, true},
+		{":w=3,7 l=12,34", WallLocation{}, false},
+		{":w=bad", WallLocation{}, false},
+		{":w=1,2 l=x,3 r", WallLocation{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseWallLocation(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: error %v", tt.in, err)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"xabbo.b7c.io/goearth/shockwave/room"
+)
+
+// RoomSnapshot is the captured state of a room. It carries everything the
+// renderer needs so a saved snapshot can be rendered without a connection.
+type RoomSnapshot struct {
+	Hotel      string
+	CapturedAt time.Time
+	Objects    []SnapshotObject
+	WallItems  []SnapshotWallItem
+}
+
+// SnapshotObject is a floor item in a RoomSnapshot
+type SnapshotObject struct {
+	Id        int
+	Class     string
+	Name      string
+	X         int
+	Y         int
+	Z         float64
+	Width     int
+	Height    int
+	Direction int
+	Revision  string
+	IconFile  string
+}
+
+// SnapshotWallItem is a wall item in a RoomSnapshot
+type SnapshotWallItem struct {
+	Id       int
+	Class    string
+	Name     string
+	Props    string
+	Location string
+	Revision string
+	IconFile string
+}
+
+// NewRoomSnapshot captures the objects and wall items of a room, ordered by
+// id so equal rooms produce equal snapshots
+func (c *Catalog) NewRoomSnapshot(objects map[int]room.Object, items map[int]room.Item) RoomSnapshot {
+	snapshot := RoomSnapshot{
+		Hotel:      c.hotel.ID,
+		CapturedAt: time.Now(),
+	}
+
+	for _, obj := range objects {
+		revision, file, _ := c.IconRef(obj.Class, "S", "")
+		snapshot.Objects = append(snapshot.Objects, SnapshotObject{
+			Id:        obj.Id,
+			Class:     obj.Class,
+			Name:      c.GetItemName(obj.Class, "S", ""),
+			X:         obj.X,
+			Y:         obj.Y,
+			Z:         obj.Z,
+			Width:     obj.Width,
+			Height:    obj.Height,
+			Direction: obj.Direction,
+			Revision:  revision,
+			IconFile:  file,
+		})
+	}
+	for _, item := range items {
+		revision, file, _ := c.IconRef(item.Class, "I", item.Type)
+		snapshot.WallItems = append(snapshot.WallItems, SnapshotWallItem{
+			Id:       item.Id,
+			Class:    item.Class,
+			Name:     c.GetItemName(item.Class, "I", item.Type),
+			Props:    item.Type,
+			Location: item.Location,
+			Revision: revision,
+			IconFile: file,
+		})
+	}
+
+	sort.Slice(snapshot.Objects, func(i, j int) bool { return snapshot.Objects[i].Id < snapshot.Objects[j].Id })
+	sort.Slice(snapshot.WallItems, func(i, j int) bool { return snapshot.WallItems[i].Id < snapshot.WallItems[j].Id })
+	return snapshot
+}
+
+// NewRoomSnapshot captures a room using the default catalog
+func NewRoomSnapshot(objects map[int]room.Object, items map[int]room.Item) RoomSnapshot {
+	return DefaultCatalog().NewRoomSnapshot(objects, items)
+}
+
+// RoomSnapshotDir is where SaveRoomSnapshot stores snapshots by default
+func RoomSnapshotDir() string {
+	return filepath.Join(ConfigDir(), "rooms")
+}
+
+// SaveRoomSnapshot writes a snapshot to path as JSON
+func SaveRoomSnapshot(path string, snapshot RoomSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// LoadRoomSnapshot reads a snapshot written by SaveRoomSnapshot
+func LoadRoomSnapshot(path string) (RoomSnapshot, error) {
+	var snapshot RoomSnapshot
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, &ParseError{URL: path, Err: err}
+	}
+	return snapshot, nil
+}
+
+// WallLocation is a parsed wall item location such as ":w=3,7 l=12,34 r".
+// WallX and WallY are the wall tile, LocalX and LocalY the pixel offset on
+// it and Side is 'l' or 'r' for the left or right wall.
+type WallLocation struct {
+	WallX  int
+	WallY  int
+	LocalX int
+	LocalY int
+	Side   byte
+}
+
+// ParseWallLocation parses the location string of a wall item
+func ParseWallLocation(location string) (WallLocation, error) {
+	var loc WallLocation
+	for _, field := range strings.Fields(strings.TrimPrefix(strings.TrimSpace(location), ":")) {
+		switch {
+		case strings.HasPrefix(field, "w="):
+			x, y, err := parseCoordinates(field[2:])
+			if err != nil {
+				return loc, fmt.Errorf("wall location %q: %w", location, err)
+			}
+			loc.WallX, loc.WallY = x, y
+		case strings.HasPrefix(field, "l="):
+			x, y, err := parseCoordinates(field[2:])
+			if err != nil {
+				return loc, fmt.Errorf("wall location %q: %w", location, err)
+			}
+			loc.LocalX, loc.LocalY = x, y
+		case field == "l" || field == "r":
+			loc.Side = field[0]
+		}
+	}
+	if loc.Side == 0 {
+		return loc, fmt.Errorf("wall location %q has no side", location)
+	}
+	return loc, nil
+}
+
+func parseCoordinates(s string) (int, int, error) {
+	parts := strings.SplitN(s, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected x,y but got %q", s)
+	}
+	x, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
@@ -0,0 +1,328 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ExternalTextsURL = "https://origins-gamedata.habbo.com/external_texts/1"
+
+	FurniDataFile     = "furnidata.json"
+	ExternalTextsFile = "external_texts.txt"
+	APIItemsFile      = "items.json"
+)
+
+// GameDataSource supplies the data sets a Catalog is built from
+type GameDataSource interface {
+	FurniData() (map[string]FurniData, error)
+	ExternalTexts() (map[string]string, error)
+	APIItems() (map[string]APIItem, error)
+}
+
+// HTTPSource downloads game data through the on-disk cache. Hotel specific
+// data sets are cached under CachePrefix so hotels never overwrite each other.
+// With Revalidate set every cached data set is checked against the server
+// instead of only the stale ones.
+type HTTPSource struct {
+	Hotel            string
+	VariablesURL     string
+	FurniDataURL     string
+	ExternalTextsURL string
+	APIItemsURL      string
+	CachePrefix      string
+	Cache            *Cache
+	Revalidate       bool
+}
+
+// NewHTTPSource returns a source for the live endpoints of the default hotel
+func NewHTTPSource() *HTTPSource {
+	return NewHotelSource(DefaultHotel)
+}
+
+// NewHotelSource returns a source for the live endpoints of hotel
+func NewHotelSource(hotel Hotel) *HTTPSource {
+	return &HTTPSource{
+		Hotel:            hotel.ID,
+		VariablesURL:     hotel.VariablesURL,
+		FurniDataURL:     hotel.FurniDataURL,
+		ExternalTextsURL: hotel.ExternalTextsURL,
+		APIItemsURL:      APIItemsURL,
+		CachePrefix:      hotel.ID,
+		Cache:            DataCache,
+	}
+}
+
+func (s *HTTPSource) cacheName(name string) string {
+	if s.CachePrefix == "" {
+		return name
+	}
+	return s.CachePrefix + "/" + name
+}
+
+func (s *HTTPSource) get(name string, url string, validate func([]byte) error) ([]byte, error) {
+	if s.Revalidate {
+		return s.Cache.Refresh(name, url, validate)
+	}
+	return s.Cache.Get(name, url, validate)
+}
+
+// FurniData loads the furnidata version currently listed in the hotel's
+// variables index, falling back to FurniDataURL when it cannot be resolved.
+// A FurniDataChange is dispatched when the version differs from the cached one.
+func (s *HTTPSource) FurniData() (map[string]FurniData, error) {
+	url := s.FurniDataURL
+	if s.VariablesURL != "" {
+		resolved, err := resolveFurniDataURL(s, s.VariablesURL)
+		if err != nil {
+			log.Printf("furnidata: using pinned location: %v", err)
+		} else {
+			url = resolved
+		}
+	}
+
+	name := s.cacheName("furnidata")
+	oldBody, oldMeta, oldErr := s.Cache.read(name)
+
+	body, err := s.get(name, url, func(b []byte) error {
+		_, err := parseFurniData(b)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := parseFurniData(body)
+	if err != nil {
+		return nil, &ParseError{URL: url, Err: err}
+	}
+
+	if meta, ok := s.Cache.Meta(name); oldErr == nil && ok && meta.URL == url && oldMeta.URL != url {
+		if old, err := parseFurniData(oldBody); err == nil {
+			added, changed, removed := DiffFurniData(old, data)
+			dispatchFurniDataChanged(FurniDataChange{
+				Hotel:      s.Hotel,
+				OldURL:     oldMeta.URL,
+				NewURL:     url,
+				OldHash:    FurniDataHash(oldMeta.URL),
+				NewHash:    FurniDataHash(url),
+				Added:      added,
+				Changed:    changed,
+				Removed:    removed,
+				DetectedAt: time.Now(),
+			})
+		}
+	}
+
+	return data, nil
+}
+
+func (s *HTTPSource) ExternalTexts() (map[string]string, error) {
+	body, err := s.get(s.cacheName("external_texts"), s.ExternalTextsURL, validateExternalTexts)
+	if err != nil {
+		return nil, err
+	}
+	return loadExternalTexts(s.ExternalTextsURL, body), nil
+}
+
+// APIItems loads the traderclub price list and records it in the price
+// history under the day it was fetched
+func (s *HTTPSource) APIItems() (map[string]APIItem, error) {
+	body, err := s.get("api_items", s.APIItemsURL, func(b []byte) error {
+		_, err := parseAPIItems(b)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseAPIItems(body)
+	if err != nil {
+		return nil, &ParseError{URL: s.APIItemsURL, Err: err}
+	}
+
+	if meta, ok := s.Cache.Meta("api_items"); ok {
+		if err := PriceHistoryStore.Record(items, meta.FetchedAt); err != nil {
+			log.Printf("history: failed to record prices: %v", err)
+		}
+	}
+	return items, nil
+}
+
+// FileSource reads game data from a directory, for example a local mirror or
+// test fixtures, using the FurniDataFile, ExternalTextsFile and APIItemsFile
+// names
+type FileSource struct {
+	Dir string
+}
+
+func NewFileSource(dir string) *FileSource {
+	return &FileSource{Dir: dir}
+}
+
+func (s *FileSource) FurniData() (map[string]FurniData, error) {
+	path := filepath.Join(s.Dir, FurniDataFile)
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := parseFurniData(body)
+	if err != nil {
+		return nil, &ParseError{URL: path, Err: err}
+	}
+	return data, nil
+}
+
+func (s *FileSource) ExternalTexts() (map[string]string, error) {
+	path := filepath.Join(s.Dir, ExternalTextsFile)
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadExternalTexts(path, body), nil
+}
+
+func (s *FileSource) APIItems() (map[string]APIItem, error) {
+	path := filepath.Join(s.Dir, APIItemsFile)
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseAPIItems(body)
+	if err != nil {
+		return nil, &ParseError{URL: path, Err: err}
+	}
+	return items, nil
+}
+
+// MemorySource serves game data that is already in memory
+type MemorySource struct {
+	Furni []FurniData
+	Texts map[string]string
+	Items []APIItem
+}
+
+func NewMemorySource(furni []FurniData, texts map[string]string, items []APIItem) *MemorySource {
+	return &MemorySource{
+		Furni: furni,
+		Texts: texts,
+		Items: items,
+	}
+}
+
+func (s *MemorySource) FurniData() (map[string]FurniData, error) {
+	result := make(map[string]FurniData)
+	for _, furni := range s.Furni {
+		result[furni.ClassName] = furni
+	}
+	return result, nil
+}
+
+func (s *MemorySource) ExternalTexts() (map[string]string, error) {
+	result := make(map[string]string)
+	for key, value := range s.Texts {
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (s *MemorySource) APIItems() (map[string]APIItem, error) {
+	result := make(map[string]APIItem)
+	for _, item := range s.Items {
+		result[item.Name] = item
+	}
+	return result, nil
+}
+
+// parseFurniData decodes floor and wall items into one map keyed by
+// classname. Wall items have WallItem set and never replace a floor item.
+func parseFurniData(body []byte) (map[string]FurniData, error) {
+	var data struct {
+		RoomItemTypes struct {
+			FurniType []FurniData `json:"furnitype"`
+		} `json:"roomitemtypes"`
+		WallItemTypes struct {
+			FurniType []FurniData `json:"furnitype"`
+		} `json:"wallitemtypes"`
+	}
+
+	err := json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]FurniData)
+	for _, furni := range data.RoomItemTypes.FurniType {
+		result[furni.ClassName] = furni
+	}
+	for _, furni := range data.WallItemTypes.FurniType {
+		if _, exists := result[furni.ClassName]; exists {
+			continue
+		}
+		furni.WallItem = true
+		result[furni.ClassName] = furni
+	}
+
+	return result, nil
+}
+
+func parseExternalTexts(body []byte) map[string]string {
+	return ParseExternalTexts(body).Texts
+}
+
+// loadExternalTexts parses an external texts body and logs the problems the
+// parser found in it
+func loadExternalTexts(name string, body []byte) map[string]string {
+	texts := ParseExternalTexts(body)
+	if texts.Encoding != EncodingUTF8 {
+		log.Printf("texts: %s decoded as %s", name, texts.Encoding)
+	}
+	if len(texts.Duplicates) > 0 {
+		keys := make([]string, 0, len(texts.Duplicates))
+		for _, duplicate := range texts.Duplicates {
+			keys = append(keys, duplicate.Key)
+		}
+		if len(keys) > 10 {
+			keys = append(keys[:10], "...")
+		}
+		log.Printf("texts: %s defines %d keys more than once: %s", name, len(texts.Duplicates), strings.Join(keys, ", "))
+	}
+	if len(texts.Malformed) > 0 {
+		log.Printf("texts: %s has %d malformed lines, first on line %d", name, len(texts.Malformed), texts.Malformed[0])
+	}
+	return texts.Texts
+}
+
+func validateExternalTexts(body []byte) error {
+	if looksLikeHTML(body) {
+		return fmt.Errorf("got an HTML page instead of key=value lines")
+	}
+	if len(parseExternalTexts(body)) == 0 {
+		return fmt.Errorf("external texts contain no entries")
+	}
+	return nil
+}
+
+// looksLikeHTML catches error and login pages served with a 200 status
+func looksLikeHTML(body []byte) bool {
+	start := strings.ToLower(strings.TrimSpace(string(body[:min(len(body), 512)])))
+	return strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+}
+
+func parseAPIItems(body []byte) (map[string]APIItem, error) {
+	var items []APIItem
+	err := json.Unmarshal(body, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]APIItem)
+	for _, item := range items {
+		result[item.Name] = item
+	}
+
+	return result, nil
+}
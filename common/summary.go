@@ -0,0 +1,447 @@
+package common
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+	"xabbo.b7c.io/goearth/shockwave/room"
+)
+
+// SummarySort orders the lines of a Summary
+type SummarySort string
+
+const (
+	SortByValue    SummarySort = "value"
+	SortByQuantity SummarySort = "quantity"
+	SortByName     SummarySort = "name"
+)
+
+// SummaryOptions control the order and length of a Summary. Totals always
+// cover every item, Limit and RaresOnly only shorten the list of lines.
+type SummaryOptions struct {
+	SortBy    SummarySort
+	Limit     int
+	RaresOnly bool
+}
+
+// DefaultSummaryOptions lists every item, most valuable first
+var DefaultSummaryOptions = SummaryOptions{SortBy: SortByValue}
+
+// Summary is the valuation of a set of items grouped by name
+type Summary struct {
+	Title            string
+	Hotel            string
+	GeneratedAt      time.Time
+	TotalUniqueItems int
+	TotalItems       int
+	TotalValue       HC
+	Sources          []string
+	PriceData        DataStatus
+	Rarity           RarityBreakdown
+	Lines            []SummaryLine
+	Omitted          int
+}
+
+// RarityBreakdown splits a valuation into rares and other items. Unpriced
+// rares are listed by name since they usually are the biggest gap in a
+// valuation.
+type RarityBreakdown struct {
+	RareItems     int
+	RareValue     HC
+	OtherItems    int
+	OtherValue    HC
+	UnpricedRares []string
+}
+
+// Add counts one item
+func (r *RarityBreakdown) Add(rare bool, value HC) {
+	if rare {
+		r.RareItems++
+		r.RareValue += value
+	} else {
+		r.OtherItems++
+		r.OtherValue += value
+	}
+}
+
+// Remove uncounts one item counted by Add
+func (r *RarityBreakdown) Remove(rare bool, value HC) {
+	if rare {
+		r.RareItems--
+		r.RareValue -= value
+	} else {
+		r.OtherItems--
+		r.OtherValue -= value
+	}
+}
+
+// SummaryLine is every item of one name in a Summary. IconURL points at the
+// hotel's image server so it can be used outside the app.
+type SummaryLine struct {
+	Name        string
+	Quantity    int
+	UnitValue   HC
+	TotalValue  HC
+	PriceSource string
+	IconURL     string
+	Rare        bool
+}
+
+// summaryBuilder counts items by name and remembers the price of each name
+type summaryBuilder struct {
+	lines   map[string]*SummaryLine
+	sources map[string]bool
+	total   int
+	totalHC HC
+	rarity  RarityBreakdown
+}
+
+func (b *summaryBuilder) add(c *Catalog, class string, itemType string, props string) {
+	name := c.GetItemName(class, itemType, props)
+	price := c.itemPrice(class, itemType, props, name)
+
+	if b.lines == nil {
+		b.lines = make(map[string]*SummaryLine)
+		b.sources = make(map[string]bool)
+	}
+	line, ok := b.lines[name]
+	if !ok {
+		furni, _ := c.Furni(class, itemType)
+		line = &SummaryLine{
+			Name:    name,
+			IconURL: c.RemoteIconURL(class, itemType, props),
+			Rare:    furni.Rare,
+		}
+		b.lines[name] = line
+	}
+	line.Quantity++
+	line.TotalValue += price.Value
+	line.PriceSource = price.Source
+	if price.Source != "" {
+		b.sources[price.Source] = true
+	}
+	b.total++
+	b.totalHC += price.Value
+	b.rarity.Add(line.Rare, price.Value)
+}
+
+func (b *summaryBuilder) build(c *Catalog, title string, opts SummaryOptions) Summary {
+	summary := Summary{
+		Title:            title,
+		Hotel:            c.hotel.Name,
+		GeneratedAt:      time.Now(),
+		TotalUniqueItems: len(b.lines),
+		TotalItems:       b.total,
+		TotalValue:       b.totalHC,
+		Sources:          sortedKeys(b.sources),
+		PriceData:        c.status.Prices,
+		Rarity:           b.rarity,
+	}
+
+	for _, line := range b.lines {
+		line.UnitValue = line.TotalValue.Div(line.Quantity)
+		if line.Rare && line.PriceSource == "" {
+			summary.Rarity.UnpricedRares = append(summary.Rarity.UnpricedRares, line.Name)
+		}
+		if opts.RaresOnly && !line.Rare {
+			continue
+		}
+		summary.Lines = append(summary.Lines, *line)
+	}
+	sort.Strings(summary.Rarity.UnpricedRares)
+	sortSummaryLines(summary.Lines, opts.SortBy)
+
+	if opts.Limit > 0 && len(summary.Lines) > opts.Limit {
+		summary.Omitted = len(summary.Lines) - opts.Limit
+		summary.Lines = summary.Lines[:opts.Limit]
+	}
+	return summary
+}
+
+// sortSummaryLines sorts by the requested key and breaks ties by name so the
+// order never depends on map iteration
+func sortSummaryLines(lines []SummaryLine, by SummarySort) {
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		switch by {
+		case SortByQuantity:
+			if a.Quantity != b.Quantity {
+				return a.Quantity > b.Quantity
+			}
+		case SortByName:
+		default:
+			if a.TotalValue != b.TotalValue {
+				return a.TotalValue > b.TotalValue
+			}
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.PriceSource < b.PriceSource
+	})
+}
+
+// InventorySummary values inventory items grouped by name
+func (c *Catalog) InventorySummary(items map[int]inventory.Item, opts SummaryOptions) Summary {
+	var b summaryBuilder
+	for _, item := range items {
+		b.add(c, item.Class, string(item.Type), item.Props)
+	}
+	return b.build(c, "Inventory", opts)
+}
+
+// RoomSummary values the floor and wall items of a room grouped by name
+func (c *Catalog) RoomSummary(objects map[int]room.Object, items map[int]room.Item, opts SummaryOptions) Summary {
+	var b summaryBuilder
+	for _, obj := range objects {
+		b.add(c, obj.Class, "S", "")
+	}
+	for _, item := range items {
+		b.add(c, item.Class, "I", item.Type)
+	}
+	return b.build(c, "Room", opts)
+}
+
+// SummaryRenderer writes a Summary in one output format
+type SummaryRenderer interface {
+	Render(w io.Writer, summary Summary) error
+	ContentType() string
+}
+
+// Summary formats with a built-in renderer
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+)
+
+var (
+	summaryRenderers = map[string]SummaryRenderer{
+		FormatText:     textSummaryRenderer{},
+		FormatMarkdown: markdownSummaryRenderer{},
+		FormatCSV:      csvSummaryRenderer{},
+		FormatJSON:     jsonSummaryRenderer{},
+		FormatHTML:     htmlSummaryRenderer{},
+	}
+	summaryRenderersMu sync.RWMutex
+)
+
+// RegisterSummaryRenderer adds or replaces the renderer for format
+func RegisterSummaryRenderer(format string, renderer SummaryRenderer) {
+	summaryRenderersMu.Lock()
+	defer summaryRenderersMu.Unlock()
+	summaryRenderers[format] = renderer
+}
+
+// SummaryRendererFor returns the renderer registered for format
+func SummaryRendererFor(format string) (SummaryRenderer, error) {
+	summaryRenderersMu.RLock()
+	defer summaryRenderersMu.RUnlock()
+	renderer, ok := summaryRenderers[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown summary format %q", format)
+	}
+	return renderer, nil
+}
+
+// RenderSummary renders summary in format and returns the result
+func RenderSummary(summary Summary, format string) (string, error) {
+	renderer, err := SummaryRendererFor(format)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := renderer.Render(&b, summary); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// String renders the summary as plain text
+func (s Summary) String() string {
+	var b strings.Builder
+	textSummaryRenderer{}.Render(&b, s)
+	return b.String()
+}
+
+// rarityText describes the rare share of the summary in one line
+func (s Summary) rarityText() string {
+	return fmt.Sprintf("%d rares worth %s HC, %d other items worth %s HC",
+		s.Rarity.RareItems, s.Rarity.RareValue, s.Rarity.OtherItems, s.Rarity.OtherValue)
+}
+
+func (s Summary) sourceList() string {
+	if len(s.Sources) == 0 {
+		return "none"
+	}
+	return strings.Join(s.Sources, ", ")
+}
+
+// rareName marks the name of a rare item with a star
+func rareName(name string, rare bool) string {
+	if rare {
+		return "★ " + name
+	}
+	return name
+}
+
+type textSummaryRenderer struct{}
+
+func (textSummaryRenderer) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (textSummaryRenderer) Render(w io.Writer, s Summary) error {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Total unique items: %d\n", s.TotalUniqueItems))
+	b.WriteString(fmt.Sprintf("Total items: %d\n", s.TotalItems))
+	b.WriteString(fmt.Sprintf("Total wealth: %s HC (values from %s)\n", s.TotalValue, s.sourceList()))
+	if !s.PriceData.Available {
+		b.WriteString(fmt.Sprintf("Price data unavailable: %s\n", s.PriceData.Message))
+	}
+	b.WriteString(fmt.Sprintf("Rares: %s\n", s.rarityText()))
+	if len(s.Rarity.UnpricedRares) > 0 {
+		b.WriteString(fmt.Sprintf("Unpriced rares: %s\n", strings.Join(s.Rarity.UnpricedRares, ", ")))
+	}
+	b.WriteString("------------------\n")
+
+	for _, line := range s.Lines {
+		name := rareName(line.Name, line.Rare)
+		if line.PriceSource == "" {
+			b.WriteString(fmt.Sprintf("%s: %d (%s HC)\n", name, line.Quantity, line.UnitValue))
+		} else {
+			b.WriteString(fmt.Sprintf("%s: %d (%s HC, %s)\n", name, line.Quantity, line.UnitValue, line.PriceSource))
+		}
+	}
+	if s.Omitted > 0 {
+		b.WriteString(fmt.Sprintf("... and %d more\n", s.Omitted))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type markdownSummaryRenderer struct{}
+
+func (markdownSummaryRenderer) ContentType() string { return "text/markdown; charset=utf-8" }
+
+func (markdownSummaryRenderer) Render(w io.Writer, s Summary) error {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("## %s summary\n\n", s.Title))
+	b.WriteString(fmt.Sprintf("- **Unique items:** %d\n", s.TotalUniqueItems))
+	b.WriteString(fmt.Sprintf("- **Items:** %d\n", s.TotalItems))
+	b.WriteString(fmt.Sprintf("- **Total value:** %s HC (values from %s)\n", s.TotalValue, s.sourceList()))
+	if !s.PriceData.Available {
+		b.WriteString(fmt.Sprintf("- **Price data unavailable:** %s\n", markdownEscape(s.PriceData.Message)))
+	}
+	b.WriteString(fmt.Sprintf("- **Rares:** %s\n", s.rarityText()))
+	if len(s.Rarity.UnpricedRares) > 0 {
+		b.WriteString(fmt.Sprintf("- **Unpriced rares:** %s\n", markdownEscape(strings.Join(s.Rarity.UnpricedRares, ", "))))
+	}
+	b.WriteString("\n| Item | Quantity | Unit HC | Total HC | Source |\n")
+	b.WriteString("| --- | ---: | ---: | ---: | --- |\n")
+	for _, line := range s.Lines {
+		name := markdownEscape(line.Name)
+		if line.Rare {
+			name = "**★ " + name + "**"
+		}
+		b.WriteString(fmt.Sprintf("| %s | %d | %s | %s | %s |\n",
+			name, line.Quantity, line.UnitValue, line.TotalValue, markdownEscape(line.PriceSource)))
+	}
+	if s.Omitted > 0 {
+		b.WriteString(fmt.Sprintf("\n_%d more items not shown_\n", s.Omitted))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`").Replace(s)
+}
+
+type csvSummaryRenderer struct{}
+
+func (csvSummaryRenderer) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (csvSummaryRenderer) Render(w io.Writer, s Summary) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"name", "quantity", "unit_hc", "total_hc", "source", "rare"})
+	for _, line := range s.Lines {
+		writer.Write([]string{
+			line.Name,
+			strconv.Itoa(line.Quantity),
+			line.UnitValue.String(),
+			line.TotalValue.String(),
+			line.PriceSource,
+			strconv.FormatBool(line.Rare),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type jsonSummaryRenderer struct{}
+
+func (jsonSummaryRenderer) ContentType() string { return "application/json" }
+
+func (jsonSummaryRenderer) Render(w io.Writer, s Summary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+type htmlSummaryRenderer struct{}
+
+func (htmlSummaryRenderer) ContentType() string { return "text/html; charset=utf-8" }
+
+var summaryPage = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} summary</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: left; }
+td.num, th.num { text-align: right; }
+.warning { color: #a33; }
+tr.rare td { background: #fdf6d8; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} summary</h1>
+<p>{{if .Hotel}}{{.Hotel}}, {{end}}{{.GeneratedAt.Format "2006-01-02 15:04"}}</p>
+<ul>
+<li>Unique items: {{.TotalUniqueItems}}</li>
+<li>Items: {{.TotalItems}}</li>
+<li>Total value: {{.TotalValue}} HC (values from {{.SourceList}})</li>
+<li>Rares: {{.RarityText}}</li>
+</ul>
+{{with .Rarity.UnpricedRares}}<p class="warning">Unpriced rares: {{range $i, $name := .}}{{if $i}}, {{end}}{{$name}}{{end}}</p>{{end}}
+{{if not .PriceData.Available}}<p class="warning">Price data unavailable: {{.PriceData.Message}}</p>{{end}}
+<table>
+<tr><th>Item</th><th class="num">Quantity</th><th class="num">Unit HC</th><th class="num">Total HC</th><th>Source</th></tr>
+{{range .Lines}}<tr{{if .Rare}} class="rare"{{end}}><td>{{if .Rare}}★ {{end}}{{.Name}}</td><td class="num">{{.Quantity}}</td><td class="num">{{.UnitValue}}</td><td class="num">{{.TotalValue}}</td><td>{{.PriceSource}}</td></tr>
+{{end}}</table>
+{{if .Omitted}}<p>{{.Omitted}} more items not shown</p>{{end}}
+</body>
+</html>
+`))
+
+func (htmlSummaryRenderer) Render(w io.Writer, s Summary) error {
+	return summaryPage.Execute(w, struct {
+		Summary
+		SourceList string
+		RarityText string
+	}{s, s.sourceList(), s.rarityText()})
+}
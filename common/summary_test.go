@@ -0,0 +1,185 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+// summaryItems are two thrones, three chairs, an unpriced red chair, two
+// garlands and an item the catalog does not know
+func summaryItems() map[int]inventory.Item {
+	items := make(map[int]inventory.Item)
+	add := func(class string, itemType string, props string, n int) {
+		for i := 0; i < n; i++ {
+			id := len(items) + 1
+			items[id] = inventory.Item{ItemId: id, Class: class, Type: inventory.ItemType(itemType), Props: props}
+		}
+	}
+	add("throne", "S", "", 2)
+	add("chair_polyfon", "S", "", 3)
+	add("chair_polyfon*4", "S", "", 1)
+	add("poster", "I", "5003", 2)
+	add("mystery", "S", "", 1)
+	return items
+}
+
+func summaryNames(summary Summary) []string {
+	names := make([]string, 0, len(summary.Lines))
+	for _, line := range summary.Lines {
+		names = append(names, line.Name)
+	}
+	return names
+}
+
+func TestInventorySummaryOrder(t *testing.T) {
+	c := loadFixtureCatalog(t)
+	usePricing(t, DefaultPricingConfig)
+	items := summaryItems()
+
+	tests := []struct {
+		sortBy SummarySort
+		want   []string
+	}{
+		{SortByValue, []string{"Throne", "Purple Garland", "Dining Chair", "Red Dining Chair", "mystery"}},
+		// Equal quantities are ordered by name
+		{SortByQuantity, []string{"Dining Chair", "Purple Garland", "Throne", "Red Dining Chair", "mystery"}},
+		{SortByName, []string{"Dining Chair", "Purple Garland", "Red Dining Chair", "Throne", "mystery"}},
+	}
+	for _, tt := range tests {
+		// Items come from a map, so build the summary a few times
+		for i := 0; i < 10; i++ {
+			summary := c.InventorySummary(items, SummaryOptions{SortBy: tt.sortBy})
+			if got := summaryNames(summary); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("%s: %v, want %v", tt.sortBy, got, tt.want)
+			}
+		}
+	}
+
+	summary := c.InventorySummary(items, DefaultSummaryOptions)
+	if summary.TotalItems != 9 || summary.TotalUniqueItems != 5 || summary.TotalValue != 10775 {
+		t.Errorf("totals %d items, %d unique, %s HC", summary.TotalItems, summary.TotalUniqueItems, summary.TotalValue)
+	}
+	want := SummaryLine{
+		Name:        "Purple Garland",
+		Quantity:    2,
+		UnitValue:   350,
+		TotalValue:  700,
+		PriceSource: SourceTraderClub,
+		IconURL:     c.RemoteIconURL("poster", "I", "5003"),
+	}
+	if summary.Lines[1] != want {
+		t.Errorf("garland line %+v, want %+v", summary.Lines[1], want)
+	}
+	if want := (RarityBreakdown{RareItems: 2, RareValue: 10000, OtherItems: 7, OtherValue: 775}); !reflect.DeepEqual(summary.Rarity, want) {
+		t.Errorf("rarity %+v, want %+v", summary.Rarity, want)
+	}
+}
+
+func TestInventorySummaryLimit(t *testing.T) {
+	c := loadFixtureCatalog(t)
+	usePricing(t, DefaultPricingConfig)
+	items := summaryItems()
+	all := c.InventorySummary(items, DefaultSummaryOptions)
+
+	tests := []struct {
+		opts    SummaryOptions
+		want    []string
+		omitted int
+	}{
+		{SummaryOptions{SortBy: SortByValue, Limit: 2}, []string{"Throne", "Purple Garland"}, 3},
+		{SummaryOptions{SortBy: SortByValue, Limit: 5}, summaryNames(all), 0},
+		{SummaryOptions{SortBy: SortByValue, Limit: 10}, summaryNames(all), 0},
+		{SummaryOptions{SortBy: SortByValue, RaresOnly: true}, []string{"Throne"}, 0},
+		{SummaryOptions{SortBy: SortByName, Limit: 1}, []string{"Dining Chair"}, 4},
+	}
+	for _, tt := range tests {
+		summary := c.InventorySummary(items, tt.opts)
+		if got := summaryNames(summary); !reflect.DeepEqual(got, tt.want) || summary.Omitted != tt.omitted {
+			t.Errorf("%+v: %v with %d omitted, want %v with %d", tt.opts, got, summary.Omitted, tt.want, tt.omitted)
+		}
+		// Totals cover every item, not only the listed ones
+		if summary.TotalItems != all.TotalItems || summary.TotalValue != all.TotalValue || summary.Rarity.OtherItems != all.Rarity.OtherItems {
+			t.Errorf("%+v: totals %d items, %s HC", tt.opts, summary.TotalItems, summary.TotalValue)
+		}
+	}
+}
+
+// goldenSummary covers every part of the renderers: an omitted tail, an
+// unpriced rare, missing price data and names that need escaping
+func goldenSummary() Summary {
+	return Summary{
+		Title:            "Inventory",
+		Hotel:            "Habbo Origins (.com)",
+		GeneratedAt:      time.Date(2024, 3, 8, 12, 30, 0, 0, time.UTC),
+		TotalUniqueItems: 4,
+		TotalItems:       7,
+		TotalValue:       10775,
+		Sources:          []string{SourceOverride, SourceTraderClub},
+		PriceData:        DataStatus{Available: false, Message: "traderclub: 503 Service Unavailable"},
+		Rarity: RarityBreakdown{
+			RareItems:     3,
+			RareValue:     10000,
+			OtherItems:    4,
+			OtherValue:    775,
+			UnpricedRares: []string{"Dragon Lamp"},
+		},
+		Lines: []SummaryLine{
+			{Name: "Throne", Quantity: 2, UnitValue: 5000, TotalValue: 10000, PriceSource: SourceTraderClub, IconURL: "https://images.example/20/throne_icon.png", Rare: true},
+			{Name: "Purple Garland", Quantity: 2, UnitValue: 350, TotalValue: 700, PriceSource: SourceOverride},
+			{Name: `Sofa | "Deluxe", <b>_1_</b>`, Quantity: 1, UnitValue: 75, TotalValue: 75, PriceSource: SourceTraderClub},
+			{Name: "Dragon Lamp", Quantity: 1, Rare: true},
+		},
+		Omitted: 1,
+	}
+}
+
+func TestRenderSummaryGolden(t *testing.T) {
+	files := map[string]string{
+		FormatText:     "summary.txt",
+		FormatMarkdown: "summary.md",
+		FormatCSV:      "summary.csv",
+		FormatJSON:     "summary.json",
+		FormatHTML:     "summary.html",
+	}
+	for format, file := range files {
+		got, err := RenderSummary(goldenSummary(), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		golden := filepath.Join("testdata", "summary", file)
+		if *updateGolden {
+			if err := writeFileAtomic(golden, []byte(got)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%v (run go test -update to create it)", err)
+		}
+		if got != string(want) {
+			t.Errorf("%s differs from %s:\n%s", format, golden, got)
+		}
+	}
+
+	if got := goldenSummary().String(); got != mustRender(t, goldenSummary(), FormatText) {
+		t.Error("String differs from the text renderer")
+	}
+	if _, err := RenderSummary(goldenSummary(), "pdf"); err == nil {
+		t.Error("unknown format rendered")
+	}
+}
+
+func mustRender(t *testing.T, summary Summary, format string) string {
+	t.Helper()
+	out, err := RenderSummary(summary, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
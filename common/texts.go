@@ -0,0 +1,197 @@
+package common
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings reported by ExternalTexts.Encoding
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingCP1252  = "windows-1252"
+)
+
+// ExternalTexts is the result of parsing an external_texts or
+// external_variables file
+type ExternalTexts struct {
+	Texts      map[string]string
+	Encoding   string
+	Duplicates []DuplicateText
+	Malformed  []int
+}
+
+// DuplicateText is a key that is defined more than once. The last
+// definition wins, like it does in the client.
+type DuplicateText struct {
+	Key   string
+	Lines []int
+}
+
+// ParseExternalTexts parses key=value lines. The body may be UTF-8 (with or
+// without a BOM), UTF-16 with a BOM, or Windows-1252/Latin-1 as served by the
+// non-English hotels. Lines starting with # or ! are comments, a line ending
+// in an odd number of backslashes continues on the next line, and values may
+// use the \n, \r, \t, \\, \= and \uXXXX escapes.
+func ParseExternalTexts(body []byte) *ExternalTexts {
+	text, encoding := decodeText(body)
+	result := &ExternalTexts{
+		Texts:    make(map[string]string),
+		Encoding: encoding,
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+
+	seen := make(map[string][]int)
+	var order []string
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		for continuesOnNextLine(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+
+		sep := keySeparator(line)
+		if sep < 0 {
+			result.Malformed = append(result.Malformed, lineNo)
+			continue
+		}
+		key := unescapeText(strings.TrimSpace(line[:sep]))
+		if key == "" {
+			result.Malformed = append(result.Malformed, lineNo)
+			continue
+		}
+		value := unescapeText(strings.TrimSpace(line[sep+1:]))
+
+		if _, ok := seen[key]; !ok {
+			order = append(order, key)
+		}
+		seen[key] = append(seen[key], lineNo)
+		result.Texts[key] = value
+	}
+
+	for _, key := range order {
+		if len(seen[key]) > 1 {
+			result.Duplicates = append(result.Duplicates, DuplicateText{Key: key, Lines: seen[key]})
+		}
+	}
+	return result
+}
+
+// decodeText converts body to a Go string and reports the encoding it was
+// read as
+func decodeText(body []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return string(bytes.ToValidUTF8(body[3:], []byte("�"))), EncodingUTF8
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return decodeUTF16(body[2:], false), EncodingUTF16LE
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return decodeUTF16(body[2:], true), EncodingUTF16BE
+	case utf8.Valid(body):
+		return string(body), EncodingUTF8
+	}
+	return decodeCP1252(body), EncodingCP1252
+}
+
+func decodeUTF16(body []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+		} else {
+			units = append(units, uint16(body[i+1])<<8|uint16(body[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// cp1252 maps the bytes 0x80-0x9F, where Windows-1252 differs from Latin-1.
+// Unassigned bytes map to the replacement character.
+var cp1252 = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+// decodeCP1252 decodes Windows-1252, which is a superset of the printable
+// part of Latin-1
+func decodeCP1252(body []byte) string {
+	var b strings.Builder
+	b.Grow(len(body))
+	for _, c := range body {
+		if c >= 0x80 && c < 0xA0 {
+			b.WriteRune(cp1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// continuesOnNextLine reports whether line ends in an unescaped backslash
+func continuesOnNextLine(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// keySeparator returns the index of the first unescaped '='
+func keySeparator(line string) int {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeText(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteString("\\u")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func parseTextsFixture(t *testing.T, name string) *ExternalTexts {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "texts", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseExternalTexts(body)
+}
+
+func TestParseExternalTexts(t *testing.T) {
+	texts := parseTextsFixture(t, "external_texts_en.txt")
+
+	want := map[string]string{
+		"furni_throne_name": "Throne",
+		"furni_throne_desc": "Important Habbos only",
+		"furni_chair_name":  "Dining Chair",
+		"furni_sofa_desc":   "Seats two,sometimes three",
+		"furni_sign_desc":   "Line one\nLine two\r\tTabbed",
+		"furni_eq_name":     "a=b",
+		"furni_café_name":   "Café",
+		"poster_5003_name":  "Purple Garland",
+	}
+	if !reflect.DeepEqual(texts.Texts, want) {
+		t.Errorf("texts:\n got %q\nwant %q", texts.Texts, want)
+	}
+	if texts.Encoding != EncodingUTF8 {
+		t.Errorf("encoding %s", texts.Encoding)
+	}
+
+	wantDuplicates := []DuplicateText{{Key: "furni_chair_name", Lines: []int{5, 13}}}
+	if !reflect.DeepEqual(texts.Duplicates, wantDuplicates) {
+		t.Errorf("duplicates %+v", texts.Duplicates)
+	}
+	if !reflect.DeepEqual(texts.Malformed, []int{11, 12}) {
+		t.Errorf("malformed lines %v", texts.Malformed)
+	}
+}
+
+func TestParseExternalTextsEncodings(t *testing.T) {
+	tests := []struct {
+		file     string
+		encoding string
+		want     map[string]string
+	}{
+		{
+			file:     "external_texts_de.txt",
+			encoding: EncodingCP1252,
+			want: map[string]string{
+				"furni_throne_name": "Königsthron",
+				"furni_sofa_name":   "Sofa für zwei",
+				"furni_euro_name":   "Preis in €",
+			},
+		},
+		{
+			file:     "external_texts_fr_utf16.txt",
+			encoding: EncodingUTF16LE,
+			want: map[string]string{
+				"furni_throne_name": "Trône",
+				"furni_chair_name":  "Chaise",
+			},
+		},
+		{
+			file:     "external_texts_es_bom.txt",
+			encoding: EncodingUTF8,
+			want:     map[string]string{"furni_throne_name": "Trono"},
+		},
+	}
+	for _, tt := range tests {
+		texts := parseTextsFixture(t, tt.file)
+		if texts.Encoding != tt.encoding {
+			t.Errorf("%s: encoding %s, want %s", tt.file, texts.Encoding, tt.encoding)
+		}
+		if !reflect.DeepEqual(texts.Texts, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.file, texts.Texts, tt.want)
+		}
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	for in, want := range map[string]string{
+		`plain`:         "plain",
+		`a\nb`:          "a\nb",
+		`a\rb`:          "a\rb",
+		`a\tb`:          "a\tb",
+		`a\\b`:          `a\b`,
+		`a\=b`:          "a=b",
+		`\u00e9t\u00e9`: "été",
+		`\u00`:          `\u00`,
+		`trailing\`:     `trailing\`,
+	} {
+		if got := unescapeText(in); got != want {
+			t.Errorf("unescapeText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SplitVariant splits a colour variant classname such as "chair_polyfon*4"
+// into its base classname and variant number. ok is false for classnames
+// without a variant suffix.
+func SplitVariant(class string) (base string, variant string, ok bool) {
+	i := strings.LastIndex(class, "*")
+	if i <= 0 || i == len(class)-1 {
+		return class, "", false
+	}
+	if _, err := strconv.Atoi(class[i+1:]); err != nil {
+		return class, "", false
+	}
+	return class[:i], class[i+1:], true
+}
+
+// BaseClass returns the classname without its colour variant suffix
+func BaseClass(class string) string {
+	base, _, _ := SplitVariant(class)
+	return base
+}
+
+// buildVariants indexes the colour variants in data by base classname
+func buildVariants(data map[string]FurniData) map[string][]string {
+	variants := make(map[string][]string)
+	for class := range data {
+		if base, _, ok := SplitVariant(class); ok {
+			variants[base] = append(variants[base], class)
+		}
+	}
+	for _, classes := range variants {
+		sort.Slice(classes, func(i, j int) bool {
+			_, a, _ := SplitVariant(classes[i])
+			_, b, _ := SplitVariant(classes[j])
+			x, _ := strconv.Atoi(a)
+			y, _ := strconv.Atoi(b)
+			return x < y
+		})
+	}
+	return variants
+}
+
+func (c *Catalog) setFurniData(data map[string]FurniData) {
+	c.furniData = data
+	c.variants = buildVariants(data)
+}
+
+// Variants returns the colour variant classnames of a base furni, in variant
+// order. It returns nil for furni that come in one colour.
+func (c *Catalog) Variants(base string) []string {
+	return c.variants[BaseClass(base)]
+}
@@ -17,15 +17,25 @@ document.querySelector('#app').innerHTML = `
                 <div class="window-content">
                     <h3>Inventory</h3>
                     <button id="scanButton">Scan Inventory</button>
+                    <button id="refreshDataButton">Refresh Prices</button>
                     <div id="inventorySummary"></div>
                 </div>
             </div>
             <div class="side-window" id="inventoryIconsWindow">
                 <div class="window-content">
                     <h3>Inventory Icons</h3>
+                    <input type="search" id="inventorySearch" placeholder="Search items">
+                    <label><input type="checkbox" id="raresOnly"> Rares only</label>
                     <div id="inventoryIcons"></div>
                 </div>
             </div>
+            <div class="side-window" id="roomRenderWindow">
+                <div class="window-content">
+                    <h3>Room</h3>
+                    <button id="renderRoomButton">Render Room</button>
+                    <img id="roomRender" alt="">
+                </div>
+            </div>
             <div class="side-window" id="itemDetailsWindow">
                 <div class="window-content">
                     <h3>Item Details</h3>
@@ -61,12 +71,15 @@ function createDebugLogElement() {
 const statusDiv = document.querySelector('#status');
 const launchButton = document.querySelector('#launchButton');
 const scanButton = document.querySelector('#scanButton');
+const refreshDataButton = document.querySelector('#refreshDataButton');
 
 // Initialize the inventory window elements
 const inventoryWindow = document.querySelector('#inventoryWindow');
 const inventorySummary = document.querySelector('#inventorySummary');
 const inventoryIcons = document.querySelector('#inventoryIcons');
 const itemDetails = document.querySelector('#itemDetails');
+const renderRoomButton = document.querySelector('#renderRoomButton');
+const roomRender = document.querySelector('#roomRender');
 
 // Initialize the room tools window elements
 const roomToolsWindow = document.querySelector('#roomToolsWindow');
@@ -89,19 +102,28 @@ document.querySelector('.close').addEventListener('click', () => {
 // Event listeners for buttons
 launchButton.addEventListener('click', launchAndEmbedHabbo);
 scanButton.addEventListener('click', startInventoryScanning);
+refreshDataButton.addEventListener('click', refreshGameData);
+renderRoomButton.addEventListener('click', renderRoom);
+document.getElementById('raresOnly').addEventListener('change', queryInventoryIcons);
+document.getElementById('inventorySearch').addEventListener('input', queryInventoryIcons);
 captureRoomButton?.addEventListener('click', captureRoom);
 acceptTradeButton?.addEventListener('click', acceptTrade);
 
 // Event listeners for Wails runtime events
 window.runtime.EventsOn("inventorySummaryUpdated", updateInventorySummary);
 window.runtime.EventsOn("inventoryDetailedSummaryUpdated", updateDetailedInventorySummary);
-window.runtime.EventsOn("inventoryIconsUpdated", updateInventoryIcons);
+window.runtime.EventsOn("inventoryIconsUpdated", queryInventoryIcons);
 window.runtime.EventsOn("inventoryItemIDsUpdated", updateInventoryItemIDs);
 window.runtime.EventsOn("inventoryScanComplete", handleInventoryScanComplete);
 window.runtime.EventsOn("inventoryScanProgress", updateScanProgress);
 window.runtime.EventsOn("roomUpdate", updateRoomDisplay);
 window.runtime.EventsOn("tradeUpdate", updateTradeDisplay);
 window.runtime.EventsOn("inventoryItemUpdated", updateInventoryItem);
+window.runtime.EventsOn("inventoryDelta", applyInventoryDelta);
+window.runtime.EventsOn("inventoryResync", applyInventoryState);
+window.runtime.EventsOn("gameDataRefreshing", handleGameDataRefreshing);
+window.runtime.EventsOn("gameDataRefreshed", handleGameDataRefreshed);
+window.runtime.EventsOn("notification", showToast);
 
 window.addEventListener('resize', () => {
     window.go.main.App.HandleResize()
@@ -141,24 +163,117 @@ function updateInventorySummary(summary) {
         <h3>Inventory Summary</h3>
         <p>Total unique items: ${summary.TotalUniqueItems}</p>
         <p>Total items: ${summary.TotalItems}</p>
-        <p>Total wealth: ${summary.TotalWealth.toFixed(2)} HC</p>
+        <p>Total wealth: ${formatWealth(summary)}</p>
+        ${formatRarity(summary.Rarity)}
+        ${summary.Stale ? `<p class="stale">Saved ${new Date(summary.CapturedAt).toLocaleString()}, scan to update</p>` : ''}
     `;
 }
 
+function formatRarity(rarity) {
+    if (!rarity) {
+        return '';
+    }
+    let html = `<p>Rares: ${rarity.RareItems} worth ${rarity.RareValue.toFixed(2)} HC</p>`;
+    if (rarity.UnpricedRares && rarity.UnpricedRares.length > 0) {
+        html += `<p class="unpriced-rares">Unpriced rares: ${rarity.UnpricedRares.join(', ')}</p>`;
+    }
+    return html;
+}
+
+function formatWealth(summary) {
+    const priceData = summary.PriceData || {};
+    if (!priceData.Available) {
+        return `price data unavailable${priceData.Message ? ` (${priceData.Message})` : ''}`;
+    }
+    if (priceData.Stale) {
+        return `${summary.TotalWealth.toFixed(2)} HC (prices may be outdated)`;
+    }
+    return `${summary.TotalWealth.toFixed(2)} HC`;
+}
+
 function updateDetailedInventorySummary(detailedSummary) {
     log(`Received detailed inventory summary`);
     inventorySummary.innerHTML += `<pre>${detailedSummary}</pre>`;
 }
 
-function updateInventoryIcons(groupedItems) {
-    log(`Received inventory icons: ${Object.keys(groupedItems).length} groups`);
+async function queryInventoryIcons() {
+    const query = {
+        Name: document.getElementById('inventorySearch').value,
+        Fuzzy: true,
+        SortBy: 'total',
+    };
+    if (document.getElementById('raresOnly').checked) {
+        query.Rare = true;
+    }
+    const result = await window.go.main.App.QueryInventory(query);
+    updateInventoryIcons(result.Items || []);
+}
+
+function updateInventoryIcons(items) {
+    log(`Received inventory icons: ${items.length} groups`);
     inventoryIcons.innerHTML = '';
-    for (const [groupKey, item] of Object.entries(groupedItems)) {
+    for (const item of items) {
         const icon = createInventoryIcon(item);
         inventoryIcons.appendChild(icon);
     }
 }
 
+// Client-side version of the name and rarity filters of QueryInventory, for
+// groups that arrive in deltas
+function matchesInventoryFilters(item) {
+    if (document.getElementById('raresOnly').checked && !item.EnrichedItem.Rare) {
+        return false;
+    }
+    const query = document.getElementById('inventorySearch').value.trim().toLowerCase();
+    const name = item.EnrichedItem.Name.toLowerCase();
+    let i = 0;
+    for (const c of name) {
+        if (i < query.length && c === query[i]) {
+            i++;
+        }
+    }
+    return i === query.length;
+}
+
+let inventorySeq = 0;
+
+function applyInventoryDelta(delta) {
+    if (delta.Seq <= inventorySeq) {
+        return;
+    }
+    if (delta.Seq !== inventorySeq + 1) {
+        log(`Missed inventory deltas ${inventorySeq + 1}-${delta.Seq - 1}, resyncing`);
+        resyncInventory();
+        return;
+    }
+    inventorySeq = delta.Seq;
+    updateInventorySummary(delta.Summary);
+    for (const change of delta.Changes) {
+        const existing = inventoryIcons.querySelector(`[data-group-key="${CSS.escape(change.GroupKey)}"]`);
+        if (change.Kind === 'removed' || !matchesInventoryFilters(change.Item)) {
+            existing?.remove();
+            continue;
+        }
+        const icon = createInventoryIcon({ ...change.Item, GroupKey: change.GroupKey });
+        if (existing) {
+            existing.replaceWith(icon);
+        } else {
+            inventoryIcons.appendChild(icon);
+        }
+    }
+}
+
+function applyInventoryState(state) {
+    inventorySeq = state.Seq;
+    updateInventorySummary(state.Summary);
+    const items = Object.entries(state.Groups || {}).map(([groupKey, item]) => ({ ...item, GroupKey: groupKey }));
+    updateInventoryIcons(items.filter(matchesInventoryFilters));
+}
+
+async function resyncInventory() {
+    applyInventoryState(await window.go.main.App.ResyncInventory());
+}
+
 function updateInventoryItemIDs(itemIDs) {
     log(`Received inventory item IDs`);
     itemDetails.innerHTML = `<pre>${itemIDs}</pre>`;
@@ -166,7 +281,8 @@ function updateInventoryItemIDs(itemIDs) {
 
 function createInventoryIcon(item) {
     const icon = document.createElement('div');
-    icon.className = 'inventory-icon';
+    icon.className = item.EnrichedItem.Rare ? 'inventory-icon rare' : 'inventory-icon';
+    icon.dataset.groupKey = item.GroupKey;
     icon.style.backgroundImage = `url(${item.EnrichedItem.IconURL})`;
     icon.title = `${item.EnrichedItem.Name} (${item.Quantity})`;
     icon.onclick = () => displayItemDetails(item);
@@ -200,6 +316,33 @@ function updateScanProgress(itemCount) {
     log(`Scan progress: ${itemCount} items scanned`);
 }
 
+async function refreshGameData() {
+    await window.go.main.App.RefreshGameData();
+}
+
+function handleGameDataRefreshing() {
+    refreshDataButton.disabled = true;
+    refreshDataButton.textContent = "Refreshing...";
+}
+
+function handleGameDataRefreshed(result) {
+    refreshDataButton.disabled = false;
+    refreshDataButton.textContent = "Refresh Prices";
+    if (result.Error) {
+        log(`Game data refresh for ${result.Hotel} failed: ${result.Error}`);
+    } else {
+        log(`Game data refreshed for ${result.Hotel}`);
+    }
+}
+
+async function renderRoom() {
+    try {
+        roomRender.src = await window.go.main.App.RenderRoom();
+    } catch (error) {
+        log(`Failed to render room: ${error}`);
+    }
+}
+
 async function captureRoom() {
     await window.go.main.App.CaptureRoom();
 }
@@ -263,22 +406,34 @@ function createTradeIcon(item) {
 }
 
 function updateInventoryItem(data) {
-    updateInventorySummary(data.summary);
-    updateInventoryIcons(data.groupedItems);
-    highlightUpdatedItem(data.updatedItem, data.isAddition);
-}
-
-function highlightUpdatedItem(item, isAddition) {
-    const icons = document.querySelectorAll('.inventory-icon');
-    for (const icon of icons) {
-        if (icon.title.includes(item.EnrichedItem.Name)) {
-            icon.classList.add(isAddition ? 'highlight-add' : 'highlight-remove');
-            setTimeout(() => {
-                icon.classList.remove('highlight-add', 'highlight-remove');
-            }, 2000);
-            break;
-        }
+    highlightUpdatedItem(data.groupKey, data.isAddition);
+}
+
+function highlightUpdatedItem(groupKey, isAddition) {
+    const icon = inventoryIcons.querySelector(`[data-group-key="${CSS.escape(groupKey)}"]`);
+    if (icon) {
+        icon.classList.add(isAddition ? 'highlight-add' : 'highlight-remove');
+        setTimeout(() => {
+            icon.classList.remove('highlight-add', 'highlight-remove');
+        }, 2000);
+    }
+}
+
+function showToast(notification) {
+    let container = document.getElementById('toasts');
+    if (!container) {
+        container = document.createElement('div');
+        container.id = 'toasts';
+        document.body.appendChild(container);
     }
+    const toast = document.createElement('div');
+    toast.className = 'toast toast-' + notification.kind.replace('.', '-');
+    const title = document.createElement('strong');
+    title.textContent = notification.title;
+    toast.appendChild(title);
+    toast.appendChild(document.createTextNode(' ' + notification.message));
+    container.appendChild(toast);
+    setTimeout(() => toast.remove(), 5000);
 }
 
 // Add this CSS to your stylesheet
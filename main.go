@@ -2,8 +2,11 @@ package main
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"embed"
+	"encoding/base64"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -69,6 +72,8 @@ type App struct {
 	uiManager        *ui.UIManager
 	unifiedInventory *ui.UnifiedInventory
 	lock             sync.Mutex
+	refreshing       bool
+	restored         *common.InventorySnapshot
 }
 
 func NewApp() *App {
@@ -92,6 +97,12 @@ func init() {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 
+	common.FurniDataChanged(func(change common.FurniDataChange) {
+		runtime.LogInfof(a.ctx, "Furnidata for %s changed from %s to %s: %d added, %d changed, %d removed",
+			change.Hotel, change.OldHash, change.NewHash, len(change.Added), len(change.Changed), len(change.Removed))
+		runtime.EventsEmit(a.ctx, "furniDataChanged", change)
+	})
+
 	// Initialize common package
 	err := common.LoadFurniData("")
 	if err != nil {
@@ -105,9 +116,268 @@ func (a *App) startup(ctx context.Context) {
 	if err != nil {
 		runtime.LogError(ctx, "Failed to load API items: "+err.Error())
 	}
+	err = common.LoadPricingConfig()
+	if err != nil {
+		runtime.LogError(ctx, "Failed to load pricing config: "+err.Error())
+	}
+
+	a.setupNotifications()
+	a.restoreInventory()
 
 	a.initializeGEarth()
-	a.uiManager = ui.NewUIManager(ctx, ext, a.inventoryManager, a.roomManager, a.profileManager, a.tradeManager, a.StartInventoryScanning)
+	a.uiManager = ui.NewUIManager(ctx, ext, a.inventoryManager, a.unifiedInventory, a.roomManager, a.profileManager, a.tradeManager, a.StartInventoryScanning)
+}
+
+// domReady shows the restored inventory once the frontend can receive
+// events
+func (a *App) domReady(ctx context.Context) {
+	if a.unifiedInventory.IsStale() {
+		a.UpdateInventoryDisplay()
+	}
+}
+
+// restoreInventory loads the last saved inventory snapshot so it can be
+// shown, marked stale, until the next scan completes
+func (a *App) restoreInventory() {
+	snapshot, err := common.LastInventorySnapshot()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			runtime.LogError(a.ctx, "Failed to restore inventory snapshot: "+err.Error())
+		}
+		return
+	}
+	a.restored = &snapshot
+	a.unifiedInventory.Restore(snapshot)
+	runtime.LogInfof(a.ctx, "Restored %d items of %s from %s", snapshot.TotalItems, snapshot.Account, snapshot.CapturedAt)
+}
+
+// saveInventorySnapshot saves a completed scan and reconciles it with the
+// snapshot that was restored at startup, if any
+func (a *App) saveInventorySnapshot(items map[int]inventory.Item) {
+	snapshot := common.NewInventorySnapshot(a.account(), items)
+
+	if a.restored != nil {
+		reconciliation := a.restored.Reconcile(snapshot)
+		a.restored = nil
+		runtime.LogInfof(a.ctx, "Inventory reconciled with snapshot: %+v", reconciliation)
+		runtime.EventsEmit(a.ctx, "inventoryReconciled", reconciliation)
+	}
+
+	if _, err := common.SaveInventorySnapshot(snapshot); err != nil {
+		runtime.LogError(a.ctx, "Failed to save inventory snapshot: "+err.Error())
+	}
+}
+
+// account returns the name of the logged in user, if known
+func (a *App) account() string {
+	if a.profileManager == nil {
+		return ""
+	}
+	return a.profileManager.Profile.Name
+}
+
+// GetInventorySnapshots lists the saved snapshots of the current account
+func (a *App) GetInventorySnapshots() ([]common.InventorySnapshotInfo, error) {
+	return common.ListInventorySnapshots(common.DefaultCatalog().Hotel().ID, a.account())
+}
+
+// inventorySnapshot loads the snapshot saved at path, or captures the
+// current inventory when path is empty
+func (a *App) inventorySnapshot(path string) (common.InventorySnapshot, error) {
+	if path == "" {
+		return common.NewInventorySnapshot(a.account(), a.unifiedInventory.InventoryItems()), nil
+	}
+	return common.LoadInventorySnapshot(path)
+}
+
+// DiffInventory compares the snapshots saved at fromPath and toPath, as
+// listed by GetInventorySnapshots. An empty toPath compares with the current
+// inventory.
+func (a *App) DiffInventory(fromPath string, toPath string) (common.InventoryDiff, error) {
+	from, err := common.LoadInventorySnapshot(fromPath)
+	if err != nil {
+		return common.InventoryDiff{}, err
+	}
+	to, err := a.inventorySnapshot(toPath)
+	if err != nil {
+		return common.InventoryDiff{}, err
+	}
+	return common.DiffInventory(from, to), nil
+}
+
+// ExportInventoryDiff renders DiffInventory as markdown or json
+func (a *App) ExportInventoryDiff(fromPath string, toPath string, format string) (string, error) {
+	diff, err := a.DiffInventory(fromPath, toPath)
+	if err != nil {
+		return "", err
+	}
+	return common.RenderInventoryDiff(diff, format)
+}
+
+// setupNotifications registers the in-app toast sink and the sinks from
+// notifications.json and starts retrying undelivered notifications
+func (a *App) setupNotifications() {
+	common.Notify.Register(&common.ToastNotifier{Emit: func(n common.Notification) {
+		runtime.EventsEmit(a.ctx, "notification", n)
+	}})
+	config, err := common.LoadNotificationConfig()
+	if err != nil {
+		runtime.LogError(a.ctx, "Failed to load notification config: "+err.Error())
+	}
+	if err := common.Notify.Configure(config); err != nil {
+		runtime.LogError(a.ctx, err.Error())
+	}
+	common.Notify.Start(time.Minute)
+}
+
+// GetPendingNotifications returns the notifications that could not be
+// delivered yet
+func (a *App) GetPendingNotifications() []common.PendingNotification {
+	return common.Notify.Pending()
+}
+
+// SetOfflineMode makes the game data loaders use only the on-disk cache
+func (a *App) SetOfflineMode(offline bool) {
+	common.DataCache.Offline.Store(offline)
+}
+
+// SetPriceOverride sets a manual price for a classname (poster_<id> for
+// posters) or display name and revalues the inventory
+func (a *App) SetPriceOverride(key string, value common.HC) error {
+	if err := common.PriceOverrides().Set(key, value); err != nil {
+		return err
+	}
+	a.revalue()
+	return nil
+}
+
+// RemovePriceOverride removes a manual price and revalues the inventory
+func (a *App) RemovePriceOverride(key string) error {
+	if err := common.PriceOverrides().Remove(key); err != nil {
+		return err
+	}
+	a.revalue()
+	return nil
+}
+
+func (a *App) GetPriceOverrides() map[string]common.HC {
+	return common.PriceOverrides().All()
+}
+
+// GetPriceMapReport lists which items are priced by classname, which only by
+// display name and which have no price at all
+func (a *App) GetPriceMapReport() common.PriceMapReport {
+	return common.DefaultCatalog().PriceMapReport()
+}
+
+// SetInventoryGrouping groups colour variants separately ("variant") or
+// under their base furni ("base")
+func (a *App) SetInventoryGrouping(mode string) {
+	if a.uiManager == nil {
+		a.unifiedInventory.SetGroupBy(ui.GroupMode(mode))
+		return
+	}
+	a.uiManager.SetInventoryGrouping(ui.GroupMode(mode))
+}
+
+// QueryInventory filters, sorts and pages the inventory groups
+func (a *App) QueryInventory(query ui.InventoryQuery) ui.InventoryQueryResult {
+	return a.unifiedInventory.Query(query)
+}
+
+// ResyncInventory returns the whole inventory for a frontend that missed an
+// "inventoryDelta" event
+func (a *App) ResyncInventory() ui.InventoryState {
+	if a.uiManager == nil {
+		return ui.InventoryState{}
+	}
+	return a.uiManager.ResyncInventory()
+}
+
+// CheckInventorySummary recomputes the inventory summary from the items and
+// reports where the running totals had drifted
+func (a *App) CheckInventorySummary() ui.SummaryDrift {
+	if a.uiManager == nil {
+		return ui.SummaryDrift{}
+	}
+	return a.uiManager.CheckInventorySummary()
+}
+
+// GetNameReport lists the items that could only be shown by classname
+func (a *App) GetNameReport() common.NameReport {
+	return common.DefaultCatalog().NameReport()
+}
+
+// GetBiggestMovers returns the n inventory items whose value changed the most
+// over the last days
+func (a *App) GetBiggestMovers(days int, n int) []common.PriceMove {
+	return common.DefaultCatalog().TopMovers(a.unifiedInventory.PriceKeys(), days, n)
+}
+
+// GetPriceHistory returns the recorded values of the traderclub item with
+// the given slug
+func (a *App) GetPriceHistory(slug string) []common.PricePoint {
+	return common.PriceHistoryStore.Series(slug)
+}
+
+// GameDataRefresh is emitted as "gameDataRefreshed" when RefreshGameData
+// finishes
+type GameDataRefresh struct {
+	Hotel  string
+	Error  string
+	Status common.CatalogStatus
+}
+
+// RefreshGameData reloads the furni data, external texts and prices of the
+// current hotel in the background. The new data replaces the old in one step
+// and everything on screen is re-enriched with it. It returns false if a
+// refresh is already running.
+func (a *App) RefreshGameData() bool {
+	a.lock.Lock()
+	if a.refreshing {
+		a.lock.Unlock()
+		return false
+	}
+	a.refreshing = true
+	a.lock.Unlock()
+
+	runtime.EventsEmit(a.ctx, "gameDataRefreshing")
+	go func() {
+		defer func() {
+			a.lock.Lock()
+			a.refreshing = false
+			a.lock.Unlock()
+		}()
+
+		host := ""
+		if hosts := common.DefaultCatalog().Hotel().Hosts; len(hosts) > 0 {
+			host = hosts[0]
+		}
+		c, err := common.ReloadCatalog(host)
+
+		result := GameDataRefresh{Hotel: c.Hotel().Name, Status: c.Status()}
+		if err != nil {
+			runtime.LogError(a.ctx, "Failed to refresh game data: "+err.Error())
+			result.Error = err.Error()
+		}
+		a.revalue()
+		runtime.EventsEmit(a.ctx, "gameDataRefreshed", result)
+	}()
+	return true
+}
+
+// GetDataStatus reports which game data sets are loaded, so the frontend can
+// tell missing prices apart from items worth 0 HC
+func (a *App) GetDataStatus() common.CatalogStatus {
+	return common.DefaultCatalog().Status()
+}
+
+func (a *App) revalue() {
+	if a.uiManager == nil {
+		a.unifiedInventory.Rebuild()
+		return
+	}
+	a.uiManager.RefreshEnrichment()
 }
 
 func (a *App) Quit() {
@@ -155,7 +425,7 @@ func setupExt() {
 
 func (a *App) setupEventHandlers() {
 	ext.Connected(func(args g.ConnectArgs) {
-		// Handle connection
+		go a.useHotel(args.Host)
 	})
 
 	ext.Initialized(func(args g.InitArgs) {
@@ -182,6 +452,7 @@ func (a *App) setupEventHandlers() {
 	a.tradeManager.Accepted(a.handleTradeAccepted)
 	a.tradeManager.Completed(a.handleTradeCompleted)
 	a.tradeManager.Closed(a.handleTradeClosed)
+	a.tradeManager.Declined(a.handleTradeDeclined)
 
 	a.roomManager.ObjectAdded(func(args room.ObjectArgs) {
 		a.addItemToRoom(args.Object)
@@ -193,6 +464,7 @@ func (a *App) setupEventHandlers() {
 
 	a.roomManager.ObjectsLoaded(func(args room.ObjectsArgs) {
 		a.updateRoomDisplay(a.roomManager.Objects, a.roomManager.Items)
+		a.notifyRoomChanged()
 	})
 
 	a.roomManager.ItemsLoaded(func(args room.ItemsArgs) {
@@ -200,6 +472,21 @@ func (a *App) setupEventHandlers() {
 	})
 }
 
+// useHotel switches the game data to the hotel serving host and re-enriches
+// everything that was already displayed
+func (a *App) useHotel(host string) {
+	changed, err := common.UseHotel(host)
+	if err != nil {
+		runtime.LogError(a.ctx, "Failed to load hotel game data: "+err.Error())
+	}
+	if !changed {
+		return
+	}
+
+	runtime.LogInfof(a.ctx, "Using game data for %s", common.DefaultCatalog().Hotel().Name)
+	a.revalue()
+}
+
 func (a *App) DownloadAndExtractZip(url string) (string, error) {
 	runtime.LogInfo(a.ctx, "Starting download from: "+url)
 	resp, err := http.Get(url)
@@ -596,6 +883,16 @@ func (a *App) HandleInventoryUpdate() {
 	items := a.inventoryManager.Items()
 	runtime.LogInfof(a.ctx, "Received %d items", len(items))
 
+	// A restored snapshot stays on screen until the fresh scan that starts
+	// with this update completes. An update without items does not start
+	// one, so it must not replace the snapshot.
+	if a.unifiedInventory.IsStale() {
+		if len(items) == 0 {
+			return
+		}
+		a.unifiedInventory.Reset()
+	}
+
 	isDone := true
 	for _, item := range items {
 		if !a.unifiedInventory.ItemExists(item.ItemId) {
@@ -607,6 +904,11 @@ func (a *App) HandleInventoryUpdate() {
 	if isDone {
 		a.UpdateInventoryDisplay()
 		runtime.EventsEmit(a.ctx, "inventoryScanComplete")
+		scanned := a.unifiedInventory.InventoryItems()
+		a.saveInventorySnapshot(scanned)
+		summary := common.DefaultCatalog().InventorySummary(scanned, common.DefaultSummaryOptions)
+		common.Notify.Send(common.NotifyScanCompleted, "Inventory scan complete",
+			fmt.Sprintf("%d items worth %s HC", summary.TotalItems, summary.TotalValue))
 	} else {
 		go func() {
 			time.Sleep(550 * time.Millisecond)
@@ -616,16 +918,14 @@ func (a *App) HandleInventoryUpdate() {
 	}
 }
 
+// UpdateInventoryDisplay emits the summary and the whole inventory, which
+// the frontend applies like the deltas that follow it
 func (a *App) UpdateInventoryDisplay() {
 	runtime.LogInfo(a.ctx, "UpdateInventoryDisplay called")
-	summary := a.unifiedInventory.GetSummary()
-	groupedItems := a.unifiedInventory.GetGroupedItems()
-
-	runtime.LogInfof(a.ctx, "Emitting inventorySummaryUpdated event: %+v", summary)
-	runtime.EventsEmit(a.ctx, "inventorySummaryUpdated", summary)
-
-	runtime.LogInfof(a.ctx, "Emitting inventoryIconsUpdated event with %d groups", len(groupedItems))
-	runtime.EventsEmit(a.ctx, "inventoryIconsUpdated", groupedItems)
+	if a.uiManager == nil {
+		return
+	}
+	a.uiManager.RefreshInventoryDisplay()
 }
 
 func (a *App) handleItemRemoval(item inventory.Item) {
@@ -634,7 +934,10 @@ func (a *App) handleItemRemoval(item inventory.Item) {
 }
 
 func (a *App) handleTradeUpdated(args trade.Args) {
-	// Update trade UI
+	if a.uiManager == nil {
+		return
+	}
+	a.uiManager.HandleTradeUpdated(args)
 }
 
 func (a *App) handleTradeAccepted(args trade.AcceptArgs) {
@@ -644,11 +947,42 @@ func (a *App) handleTradeAccepted(args trade.AcceptArgs) {
 func (a *App) handleTradeCompleted(args trade.Args) {
 	// Handle trade completion
 	// Update inventory
+	own, other := args.Offers[0], args.Offers[1]
+	if other.Name == a.account() {
+		own, other = other, own
+	}
+	gave := valueTradeItems(own.Items)
+	received := valueTradeItems(other.Items)
+	common.Notify.Send(common.NotifyTradeCompleted, "Trade completed",
+		fmt.Sprintf("Traded with %s", other.Name),
+		common.Field{Name: "Gave", Value: fmt.Sprintf("%d items, %s HC", len(gave.Items), gave.Total), Inline: true},
+		common.Field{Name: "Received", Value: fmt.Sprintf("%d items, %s HC", len(received.Items), received.Total), Inline: true})
+
+	if a.uiManager != nil {
+		// The UI manager takes the first offer to be our own
+		a.uiManager.HandleTradeCompleted(trade.Args{Offers: trade.Offers{own, other}})
+	}
+}
+
+func (a *App) handleTradeDeclined(args trading.DeclinedArgs) {
+	offered := valueTradeItems(args.Items)
+	common.Notify.Send(common.NotifyTradeDeclined, "Trade declined",
+		fmt.Sprintf("The trade with %s was closed with %d items worth %s HC on offer", args.Name, len(offered.Items), offered.Total))
+}
+
+func valueTradeItems(items []inventory.Item) common.Valuation {
+	enriched := make([]common.EnrichedInventoryItem, 0, len(items))
+	for _, item := range items {
+		enriched = append(enriched, common.EnrichInventoryItem(item))
+	}
+	return common.ValueItems(enriched)
 }
 
 func (a *App) handleTradeClosed(args trade.Args) {
-	// Handle trade closure
-	// Reset trade UI
+	if a.uiManager == nil {
+		return
+	}
+	a.uiManager.HandleTradeClosed(args)
 }
 
 func (a *App) addItemToRoom(item room.Object) {
@@ -663,6 +997,13 @@ func (a *App) updateRoomDisplay(objects map[int]room.Object, items map[int]room.
 	// Update room display
 }
 
+// notifyRoomChanged reports the room that was just loaded
+func (a *App) notifyRoomChanged() {
+	summary := common.DefaultCatalog().RoomSummary(a.roomManager.Objects, a.roomManager.Items, common.DefaultSummaryOptions)
+	common.Notify.Send(common.NotifyRoomChanged, "Room loaded",
+		fmt.Sprintf("%d items worth %s HC", summary.TotalItems, summary.TotalValue))
+}
+
 func (a *App) StartInventoryScanning() {
 	runtime.LogInfo(a.ctx, "StartInventoryScanning called")
 	if a.inventoryManager == nil {
@@ -670,7 +1011,7 @@ func (a *App) StartInventoryScanning() {
 		return
 	}
 	// Clear existing inventory
-	a.unifiedInventory = ui.NewUnifiedInventory()
+	a.unifiedInventory.Reset()
 
 	// Trigger inventory scan
 	a.inventoryManager.Update()
@@ -681,6 +1022,91 @@ func (a *App) CaptureRoom() string {
 	return "Room captured"
 }
 
+// ExportInventorySummary renders the inventory summary as text, markdown,
+// csv, json or html. sortBy is value, quantity or name, limit caps the
+// number of items listed when it is above zero and raresOnly lists only
+// rares.
+func (a *App) ExportInventorySummary(format string, sortBy string, limit int, raresOnly bool) (string, error) {
+	opts := common.SummaryOptions{SortBy: common.SummarySort(sortBy), Limit: limit, RaresOnly: raresOnly}
+	summary := common.DefaultCatalog().InventorySummary(a.unifiedInventory.InventoryItems(), opts)
+	return common.RenderSummary(summary, format)
+}
+
+// ExportRoomSummary renders the current room's summary, see
+// ExportInventorySummary
+func (a *App) ExportRoomSummary(format string, sortBy string, limit int, raresOnly bool) (string, error) {
+	if a.roomManager == nil {
+		return "", fmt.Errorf("not connected to a room")
+	}
+	opts := common.SummaryOptions{SortBy: common.SummarySort(sortBy), Limit: limit, RaresOnly: raresOnly}
+	summary := common.DefaultCatalog().RoomSummary(a.roomManager.Objects, a.roomManager.Items, opts)
+	return common.RenderSummary(summary, format)
+}
+
+// roomSnapshot captures the current room
+func (a *App) roomSnapshot() common.RoomSnapshot {
+	if a.roomManager == nil {
+		return common.RoomSnapshot{}
+	}
+	return common.NewRoomSnapshot(a.roomManager.Objects, a.roomManager.Items)
+}
+
+// RenderRoom returns an isometric picture of the current room as a PNG data
+// URL
+func (a *App) RenderRoom() (string, error) {
+	var buf bytes.Buffer
+	if err := common.EncodeRoomPNG(&buf, a.roomSnapshot(), common.IconCacheLoader(common.Icons)); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// SaveRoomSnapshot saves the current room so it can be rendered later and
+// returns the path it was saved to
+func (a *App) SaveRoomSnapshot() (string, error) {
+	snapshot := a.roomSnapshot()
+	path := filepath.Join(common.RoomSnapshotDir(), snapshot.CapturedAt.Format("20060102-150405")+".json")
+	return path, common.SaveRoomSnapshot(path, snapshot)
+}
+
+// SendInventoryReport posts the inventory summary to a Discord webhook
+func (a *App) SendInventoryReport(webhookURL string) error {
+	summary := common.DefaultCatalog().InventorySummary(a.unifiedInventory.InventoryItems(), common.DefaultSummaryOptions)
+	return common.SendToDiscord(webhookURL, common.SummaryEmbeds(summary))
+}
+
+// SendRoomReport posts the current room's summary to a Discord webhook,
+// with a render of the room attached when includeRender is set
+func (a *App) SendRoomReport(webhookURL string, includeRender bool) error {
+	if a.roomManager == nil {
+		return fmt.Errorf("not connected to a room")
+	}
+	summary := common.DefaultCatalog().RoomSummary(a.roomManager.Objects, a.roomManager.Items, common.DefaultSummaryOptions)
+	embeds := common.SummaryEmbeds(summary)
+	if !includeRender {
+		return common.SendToDiscord(webhookURL, embeds)
+	}
+
+	var buf bytes.Buffer
+	if err := common.EncodeRoomPNG(&buf, a.roomSnapshot(), common.IconCacheLoader(common.Icons)); err != nil {
+		return err
+	}
+	embeds[0].Image = &common.Image{URL: "attachment://room.png"}
+	return common.NewDiscordWebhook(webhookURL).Send(embeds, common.DiscordFile{Name: "room.png", Data: buf.Bytes()})
+}
+
+// SendTradeReport posts both sides of the current trade to a Discord webhook
+func (a *App) SendTradeReport(webhookURL string) error {
+	if a.uiManager == nil {
+		return fmt.Errorf("not connected")
+	}
+	embeds, err := a.uiManager.TradeReport()
+	if err != nil {
+		return err
+	}
+	return common.SendToDiscord(webhookURL, embeds)
+}
+
 func (a *App) PickupItems(itemIds []int) {
 	for _, id := range itemIds {
 		ext.Send(out.ADDSTRIPITEM, []byte(fmt.Sprintf("new stuff %d", id)))
@@ -712,6 +1138,15 @@ func (a *App) PlaceItem(itemId int, x, y int) {
 }
 
 func main() {
+	offline := flag.Bool("offline", false, "load game data from the local cache only")
+	gameDataDir := flag.String("gamedata-dir", "", "load game data from a local directory instead of the hotel")
+	flag.Parse()
+	common.DataCache.Offline.Store(*offline)
+
+	if *gameDataDir != "" {
+		common.SetDefaultSource(common.NewFileSource(*gameDataDir))
+	}
+
 	app := NewApp()
 
 	err := wails.Run(&options.App{
@@ -720,10 +1155,12 @@ func main() {
 		Height:    768,
 		Frameless: true,
 		AssetServer: &assetserver.Options{
-			Assets: assets,
+			Assets:  assets,
+			Handler: common.Icons,
 		},
 		BackgroundColour: &options.RGBA{R: 255, G: 255, B: 255, A: 0},
 		OnStartup:        app.startup,
+		OnDomReady:       app.domReady,
 		Bind: []interface{}{
 			app,
 		},
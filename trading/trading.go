@@ -43,6 +43,14 @@ type Manager struct {
 	lastTrade         trade.Offers
 	lock              sync.Mutex
 	isTradeOpen       bool
+	declined          []func(DeclinedArgs)
+}
+
+// DeclinedArgs describes a trade that was closed after the other party had
+// offered items, without being completed
+type DeclinedArgs struct {
+	Name  string
+	Items []inventory.Item
 }
 
 func (m *Manager) IsInTrade(itemId int) bool {
@@ -74,6 +82,14 @@ func NewManager(ext *g.Ext, profileMgr *profile.Manager, inventoryMgr *inventory
 	return mgr
 }
 
+// Declined registers a handler called when a trade is closed after the
+// other party had offered items
+func (m *Manager) Declined(handler func(DeclinedArgs)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.declined = append(m.declined, handler)
+}
+
 func (m *Manager) Offer(itemId int) {
 	m.Manager.Offer(itemId)
 }
@@ -93,6 +109,7 @@ func (m *Manager) Unaccept() {
 func (m *Manager) handleTradeItems(args trade.Args) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	m.lastTrade = args.Offers
 	clear(m.isInTrade)
 	m.tradingQty = 0
 	m.warnTradeDeclined = false
@@ -126,14 +143,26 @@ func (m *Manager) handleTradeComplete(args trade.Args) {
 
 func (m *Manager) handleTradeClose(args trade.Args) {
 	m.lock.Lock()
-	defer m.lock.Unlock()
 	m.tradingItem = ""
 	m.tradingItemProps = ""
 	m.targetQty = 0
 	m.isTradeOpen = false // Set this to false when trade ends
+	var handlers []func(DeclinedArgs)
+	var declined DeclinedArgs
 	if m.warnTradeDeclined {
 		m.warnTradeDeclined = false
-		// Notify user about trade cancellation
+		handlers = m.declined
+		for _, offer := range m.lastTrade {
+			if offer.Name != m.profileMgr.Profile.Name {
+				declined = DeclinedArgs{Name: offer.Name, Items: offer.Items}
+			}
+		}
+	}
+	m.lock.Unlock()
+
+	// Notify user about trade cancellation
+	for _, handler := range handlers {
+		handler(declined)
 	}
 }
 
@@ -0,0 +1,130 @@
+package ui
+
+import "sort"
+
+// DeltaKind is how an inventory group changed
+type DeltaKind string
+
+const (
+	DeltaAdded   DeltaKind = "added"
+	DeltaChanged DeltaKind = "changed"
+	DeltaRemoved DeltaKind = "removed"
+)
+
+// GroupDelta is the change of one inventory group. Item is the group as it
+// is now and is nil when the group was removed.
+type GroupDelta struct {
+	Kind     DeltaKind
+	GroupKey string
+	Item     *UnifiedItem
+}
+
+// InventoryDelta is emitted as "inventoryDelta" with the groups that changed
+// since the previous delta or resync. Seq is one more than the Seq of the
+// previous delta or resync; a frontend that sees a gap calls
+// ResyncInventory. The summary is sent without its per-name items.
+type InventoryDelta struct {
+	Seq     uint64
+	Changes []GroupDelta
+	Summary InventorySummary
+}
+
+// InventoryState is the whole inventory, emitted as "inventoryResync" and
+// returned by ResyncInventory. Deltas continue from Seq.
+type InventoryState struct {
+	Seq     uint64
+	Groups  map[string]UnifiedItem
+	Summary InventorySummary
+}
+
+// markChanged records a change of a group for the next delta, folding it
+// into any change already recorded since the last one
+func (ui *UnifiedInventory) markChanged(groupKey string, kind DeltaKind) {
+	if ui.changes == nil {
+		ui.changes = make(map[string]DeltaKind)
+	}
+	previous, ok := ui.changes[groupKey]
+	switch {
+	case !ok:
+		ui.changes[groupKey] = kind
+	case previous == DeltaAdded && kind == DeltaRemoved:
+		delete(ui.changes, groupKey)
+	case previous == DeltaAdded:
+	case previous == DeltaRemoved && kind == DeltaAdded:
+		ui.changes[groupKey] = DeltaChanged
+	default:
+		ui.changes[groupKey] = kind
+	}
+}
+
+// TakeDelta returns the group changes recorded since the last TakeDelta or
+// State, ordered by group key, and the summary without its items
+func (ui *UnifiedInventory) TakeDelta() ([]GroupDelta, InventorySummary) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	changes := make([]GroupDelta, 0, len(ui.changes))
+	for groupKey, kind := range ui.changes {
+		change := GroupDelta{Kind: kind, GroupKey: groupKey}
+		if kind != DeltaRemoved {
+			unifiedItem := ui.Items[groupKey].clone()
+			change.Item = &unifiedItem
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].GroupKey < changes[j].GroupKey })
+	ui.changes = nil
+
+	summary := ui.summary()
+	summary.Items = nil
+	return changes, summary
+}
+
+// State returns a copy of every group and the summary, and forgets the
+// changes recorded so far since the state already includes them
+func (ui *UnifiedInventory) State() (map[string]UnifiedItem, InventorySummary) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	groups := make(map[string]UnifiedItem, len(ui.Items))
+	for groupKey, unifiedItem := range ui.Items {
+		groups[groupKey] = unifiedItem.clone()
+	}
+	ui.changes = nil
+	return groups, ui.summary()
+}
+
+// emitInventoryDelta sends the group changes since the last delta or resync,
+// if there are any
+func (m *UIManager) emitInventoryDelta() {
+	m.deltaMu.Lock()
+	defer m.deltaMu.Unlock()
+
+	changes, summary := m.unifiedInventory.TakeDelta()
+	if len(changes) == 0 {
+		return
+	}
+	m.inventorySeq++
+	eventsEmit(m.ctx, "inventoryDelta", InventoryDelta{
+		Seq:     m.inventorySeq,
+		Changes: changes,
+		Summary: summary,
+	})
+}
+
+// ResyncInventory returns the whole inventory and continues the delta
+// sequence from it
+func (m *UIManager) ResyncInventory() InventoryState {
+	m.deltaMu.Lock()
+	defer m.deltaMu.Unlock()
+
+	groups, summary := m.unifiedInventory.State()
+	m.inventorySeq++
+	return InventoryState{Seq: m.inventorySeq, Groups: groups, Summary: summary}
+}
+
+// emitInventoryResync sends the whole inventory, for changes such as a new
+// scan or regrouping that touch every group
+func (m *UIManager) emitInventoryResync() {
+	eventsEmit(m.ctx, "inventoryResync", m.ResyncInventory())
+}
@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"context"
+	"testing"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+func TestTakeDeltaFoldsChanges(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "chair_polyfon", Type: "S"})
+	inv.AddItem(inventory.Item{ItemId: 2, Class: "chair_polyfon", Type: "S"})
+
+	changes, summary := inv.TakeDelta()
+	if len(changes) != 1 || changes[0].Kind != DeltaAdded || changes[0].Item.Quantity != 2 {
+		t.Fatalf("changes %+v", changes)
+	}
+	if summary.TotalItems != 2 || summary.Items != nil {
+		t.Errorf("summary %+v, want 2 items without the per-name items", summary)
+	}
+
+	// Added and removed again before the next delta is no change at all
+	inv.AddItem(inventory.Item{ItemId: 3, Class: "throne", Type: "S"})
+	inv.RemoveItem(3)
+	inv.RemoveItem(1)
+	changes, _ = inv.TakeDelta()
+	if len(changes) != 1 || changes[0].GroupKey != "chair_polyfon" || changes[0].Kind != DeltaChanged {
+		t.Fatalf("changes %+v", changes)
+	}
+
+	// Removed and added again is a change of the group
+	inv.RemoveItem(2)
+	inv.AddItem(inventory.Item{ItemId: 2, Class: "chair_polyfon", Type: "S"})
+	changes, _ = inv.TakeDelta()
+	if len(changes) != 1 || changes[0].Kind != DeltaChanged {
+		t.Fatalf("changes %+v", changes)
+	}
+
+	inv.RemoveItem(2)
+	changes, _ = inv.TakeDelta()
+	if len(changes) != 1 || changes[0].Kind != DeltaRemoved || changes[0].Item != nil {
+		t.Fatalf("changes %+v", changes)
+	}
+}
+
+func TestStateForgetsChanges(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+
+	groups, summary := inv.State()
+	if len(groups) != 1 || summary.TotalItems != 1 {
+		t.Fatalf("state %v, %+v", groups, summary)
+	}
+	if changes, _ := inv.TakeDelta(); len(changes) != 0 {
+		t.Errorf("changes already in the state were kept: %+v", changes)
+	}
+}
+
+func TestUIManagerSharesInventory(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	m := NewUIManager(context.Background(), nil, nil, inv, nil, nil, nil, nil)
+
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+	first := m.ResyncInventory()
+	if len(first.Groups) != 1 {
+		t.Fatalf("manager does not see the app's inventory: %+v", first)
+	}
+	if item, ok := m.FindItemById(1); !ok || item.Class != "throne" {
+		t.Errorf("FindItemById(1) = %+v, %v", item, ok)
+	}
+
+	inv.Reset()
+	second := m.ResyncInventory()
+	if len(second.Groups) != 0 || second.Seq != first.Seq+1 {
+		t.Errorf("after Reset: %+v", second)
+	}
+}
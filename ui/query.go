@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bolognesandwiches/G-itemViewer/common"
+)
+
+// QuerySort orders the results of an InventoryQuery
+type QuerySort string
+
+const (
+	QuerySortValue      QuerySort = "value"
+	QuerySortQuantity   QuerySort = "quantity"
+	QuerySortName       QuerySort = "name"
+	QuerySortTotalValue QuerySort = "total"
+)
+
+// InventoryQuery filters, sorts and pages the groups of a UnifiedInventory.
+// Zero values leave a filter off. Name matches as a case-insensitive
+// substring, or with Fuzzy as the letters of Name in order anywhere in the
+// item name. Class matches the classname or the base classname of colour
+// variants. Values are per item. Page counts from 1 and a PageSize of 0
+// returns every match on one page.
+type InventoryQuery struct {
+	Name        string
+	Fuzzy       bool
+	Class       string
+	Type        string
+	MinValue    *common.HC
+	MaxValue    *common.HC
+	Rare        *bool
+	InTrade     *bool
+	MinQuantity int
+	MaxQuantity int
+	SortBy      QuerySort
+	Reverse     bool
+	Page        int
+	PageSize    int
+}
+
+// InventoryQueryItem is one group matched by an InventoryQuery
+type InventoryQueryItem struct {
+	UnifiedItem
+	GroupKey   string
+	TotalValue common.HC
+}
+
+// InventoryQueryResult is one page of matches. Total counts the matches on
+// every page.
+type InventoryQueryResult struct {
+	Items    []InventoryQueryItem
+	Total    int
+	Page     int
+	PageSize int
+	Pages    int
+}
+
+// Query returns the groups matching q, sorted and paged
+func (ui *UnifiedInventory) Query(q InventoryQuery) InventoryQueryResult {
+	ui.mu.RLock()
+	var matches []InventoryQueryItem
+	for groupKey, unifiedItem := range ui.Items {
+		if q.matches(unifiedItem) {
+			matches = append(matches, InventoryQueryItem{
+				UnifiedItem: unifiedItem.clone(),
+				GroupKey:    groupKey,
+				TotalValue:  ui.groupValue(unifiedItem),
+			})
+		}
+	}
+	ui.mu.RUnlock()
+
+	sortQueryItems(matches, q.SortBy, q.Reverse)
+
+	result := InventoryQueryResult{Total: len(matches), Page: 1, Pages: 1}
+	if q.PageSize <= 0 {
+		result.Items = matches
+		result.PageSize = len(matches)
+		return result
+	}
+
+	result.PageSize = q.PageSize
+	result.Pages = max((len(matches)+q.PageSize-1)/q.PageSize, 1)
+	result.Page = min(max(q.Page, 1), result.Pages)
+	start := (result.Page - 1) * q.PageSize
+	end := min(start+q.PageSize, len(matches))
+	result.Items = matches[start:end]
+	return result
+}
+
+func (q InventoryQuery) matches(unifiedItem UnifiedItem) bool {
+	item := unifiedItem.EnrichedItem
+	if q.Name != "" && !matchName(item.Name, q.Name, q.Fuzzy) {
+		return false
+	}
+	if q.Class != "" && !strings.EqualFold(item.Class, q.Class) && !strings.EqualFold(item.BaseClass, q.Class) {
+		return false
+	}
+	if q.Type != "" && !strings.EqualFold(string(item.Type), q.Type) {
+		return false
+	}
+	if q.MinValue != nil && item.HCValue < *q.MinValue {
+		return false
+	}
+	if q.MaxValue != nil && item.HCValue > *q.MaxValue {
+		return false
+	}
+	if q.Rare != nil && item.Rare != *q.Rare {
+		return false
+	}
+	if q.InTrade != nil && unifiedItem.InTrade != *q.InTrade {
+		return false
+	}
+	if q.MinQuantity > 0 && unifiedItem.Quantity < q.MinQuantity {
+		return false
+	}
+	if q.MaxQuantity > 0 && unifiedItem.Quantity > q.MaxQuantity {
+		return false
+	}
+	return true
+}
+
+// matchName matches query as a substring of name, or with fuzzy as a
+// subsequence of it, ignoring case
+func matchName(name string, query string, fuzzy bool) bool {
+	name = strings.ToLower(name)
+	query = strings.ToLower(strings.TrimSpace(query))
+	if strings.Contains(name, query) {
+		return true
+	}
+	if !fuzzy {
+		return false
+	}
+	rest := []rune(query)
+	for _, r := range name {
+		if len(rest) == 0 {
+			break
+		}
+		if r == rest[0] {
+			rest = rest[1:]
+		}
+	}
+	return len(rest) == 0
+}
+
+// sortQueryItems sorts by the requested key, most first except for names,
+// and breaks ties by group key so the order never depends on map iteration
+func sortQueryItems(items []InventoryQueryItem, by QuerySort, reverse bool) {
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if reverse {
+			a, b = b, a
+		}
+		switch by {
+		case QuerySortQuantity:
+			if a.Quantity != b.Quantity {
+				return a.Quantity > b.Quantity
+			}
+		case QuerySortName:
+			if an, bn := strings.ToLower(a.EnrichedItem.Name), strings.ToLower(b.EnrichedItem.Name); an != bn {
+				return an < bn
+			}
+		case QuerySortTotalValue:
+			if a.TotalValue != b.TotalValue {
+				return a.TotalValue > b.TotalValue
+			}
+		default:
+			if a.EnrichedItem.HCValue != b.EnrichedItem.HCValue {
+				return a.EnrichedItem.HCValue > b.EnrichedItem.HCValue
+			}
+		}
+		return a.GroupKey < b.GroupKey
+	})
+}
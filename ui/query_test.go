@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/bolognesandwiches/G-itemViewer/common"
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+func queryFixture(t *testing.T, mode GroupMode) *UnifiedInventory {
+	t.Helper()
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.SetGroupBy(mode)
+	for i, class := range []string{"chair_polyfon", "chair_polyfon*4", "chair_polyfon*4", "throne"} {
+		inv.AddItem(inventory.Item{ItemId: i + 1, Class: class, Type: "S"})
+	}
+	return inv
+}
+
+func TestQueryTotalValueOfMixedGroup(t *testing.T) {
+	inv := queryFixture(t, GroupByBase)
+
+	result := inv.Query(InventoryQuery{SortBy: QuerySortTotalValue})
+	if result.Total != 2 {
+		t.Fatalf("want 2 groups, got %d", result.Total)
+	}
+	// One plain chair at 0.25 and two red ones at 1.00, not three times
+	// whichever variant the group was created from
+	chairs := result.Items[1]
+	if chairs.GroupKey != "chair_polyfon" || chairs.TotalValue != 225 {
+		t.Errorf("%s worth %s, want chair_polyfon worth 2.25", chairs.GroupKey, chairs.TotalValue)
+	}
+	if result.Items[0].GroupKey != "throne" || result.Items[0].TotalValue != 5000 {
+		t.Errorf("first %s worth %s", result.Items[0].GroupKey, result.Items[0].TotalValue)
+	}
+}
+
+func TestQueryFiltersAndPages(t *testing.T) {
+	inv := queryFixture(t, GroupByVariant)
+	rare := true
+	minValue := common.HC(50)
+
+	tests := []struct {
+		query InventoryQuery
+		want  []string
+	}{
+		{InventoryQuery{Name: "dining", SortBy: QuerySortName}, []string{"chair_polyfon", "chair_polyfon*4"}},
+		{InventoryQuery{Name: "rdc", Fuzzy: true}, []string{"chair_polyfon*4"}},
+		{InventoryQuery{Class: "chair_polyfon", SortBy: QuerySortQuantity}, []string{"chair_polyfon*4", "chair_polyfon"}},
+		{InventoryQuery{Rare: &rare}, []string{"throne"}},
+		{InventoryQuery{MinValue: &minValue, SortBy: QuerySortValue, Reverse: true}, []string{"chair_polyfon*4", "throne"}},
+		{InventoryQuery{SortBy: QuerySortTotalValue, PageSize: 2, Page: 2}, []string{"chair_polyfon"}},
+	}
+	for _, tt := range tests {
+		result := inv.Query(tt.query)
+		var got []string
+		for _, item := range result.Items {
+			got = append(got, item.GroupKey)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%+v: got %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestQueryCopiesItems(t *testing.T) {
+	inv := queryFixture(t, GroupByVariant)
+	result := inv.Query(InventoryQuery{Class: "chair_polyfon*4"})
+	if len(result.Items) != 1 {
+		t.Fatalf("got %d groups", len(result.Items))
+	}
+	items := result.Items[0].Items
+
+	inv.AddItem(inventory.Item{ItemId: 10, Class: "chair_polyfon*4", Type: "S"})
+	inv.RemoveItem(2)
+	if len(items) != 2 || items[0].ItemId != 2 || items[1].ItemId != 3 {
+		t.Errorf("query result changed with the inventory: %+v", items)
+	}
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+	"xabbo.b7c.io/goearth/shockwave/trade"
+)
+
+// emitted is an event sent to the frontend
+type emitted struct {
+	name string
+	data []interface{}
+}
+
+// recordEvents collects the events emitted for the rest of the test
+func recordEvents(t *testing.T) func() []emitted {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		events []emitted
+	)
+	previous := eventsEmit
+	eventsEmit = func(ctx context.Context, name string, data ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, emitted{name, data})
+	}
+	t.Cleanup(func() { eventsEmit = previous })
+	return func() []emitted {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]emitted(nil), events...)
+	}
+}
+
+func TestTradeReport(t *testing.T) {
+	useCatalog(t, 5000)
+	events := recordEvents(t)
+	m := NewUIManager(context.Background(), nil, nil, NewUnifiedInventory(), nil, nil, nil, nil)
+
+	if _, err := m.TradeReport(); err == nil {
+		t.Fatal("report before any trade")
+	}
+
+	m.HandleTradeUpdated(trade.Args{Offers: trade.Offers{
+		{Name: "alice", Items: []inventory.Item{{ItemId: 1, Class: "throne", Type: "S"}}},
+		{Name: "bob", Items: []inventory.Item{
+			{ItemId: 2, Class: "chair_polyfon", Type: "S"},
+			{ItemId: 3, Class: "chair_polyfon", Type: "S"},
+		}},
+	}})
+
+	var valuation TradeValuation
+	for _, event := range events() {
+		if event.name == "tradeValuation" {
+			valuation = event.data[0].(TradeValuation)
+		}
+	}
+	if valuation.TraderName != "alice" || valuation.Trader.Total != 5000 || valuation.Tradee.Total != 50 {
+		t.Fatalf("trade valuation %+v", valuation)
+	}
+	if len(valuation.Tradee.Items) != 2 || len(valuation.Trader.Sources) != 1 {
+		t.Errorf("trade valuation items %+v, sources %v", valuation.Tradee.Items, valuation.Trader.Sources)
+	}
+
+	embeds, err := m.TradeReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embeds) != 2 || embeds[0].Title != "alice offers" || embeds[1].Title != "bob offers" {
+		t.Fatalf("embeds %+v", embeds)
+	}
+	if !strings.Contains(embeds[0].Description, "Total value: **50.00 HC**") || !strings.Contains(embeds[0].Description, "Difference: +49.50 HC") {
+		t.Errorf("alice's side: %q", embeds[0].Description)
+	}
+	if !strings.Contains(embeds[1].Description, "Total value: **0.50 HC**") {
+		t.Errorf("bob's side: %q", embeds[1].Description)
+	}
+}
@@ -2,7 +2,10 @@ package ui
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/bolognesandwiches/G-itemViewer/common"
 	"github.com/bolognesandwiches/G-itemViewer/trading"
@@ -14,6 +17,10 @@ import (
 	"xabbo.b7c.io/goearth/shockwave/trade"
 )
 
+// eventsEmit sends an event to the frontend. Tests replace it to see the
+// events without a running app.
+var eventsEmit = runtime.EventsEmit
+
 type UIManager struct {
 	ctx              context.Context
 	ext              *g.Ext
@@ -22,7 +29,10 @@ type UIManager struct {
 	tradeManager     *trading.Manager
 	profileManager   *profile.Manager
 	unifiedInventory *UnifiedInventory
+	lastTrade        *TradeValuation
 	mu               sync.Mutex
+	inventorySeq     uint64
+	deltaMu          sync.Mutex
 }
 
 type UnifiedItem struct {
@@ -30,27 +40,106 @@ type UnifiedItem struct {
 	EnrichedItem common.EnrichedInventoryItem
 	Quantity     int
 	InTrade      bool
+	Variants     map[string]int
+}
+
+// clone copies the group so it can be handed out after the inventory's lock
+// is released
+func (u UnifiedItem) clone() UnifiedItem {
+	u.Items = append([]inventory.Item(nil), u.Items...)
+	variants := make(map[string]int, len(u.Variants))
+	for class, n := range u.Variants {
+		variants[class] = n
+	}
+	u.Variants = variants
+	return u
 }
 
+// GroupMode decides whether colour variants of a furni share a group
+type GroupMode string
+
+const (
+	GroupByVariant GroupMode = "variant"
+	GroupByBase    GroupMode = "base"
+)
+
 type UnifiedInventory struct {
-	Items   map[string]UnifiedItem
-	Summary InventorySummary
-	mu      sync.RWMutex
+	Items      map[string]UnifiedItem
+	Summary    InventorySummary
+	GroupBy    GroupMode
+	stale      bool
+	capturedAt time.Time
+	changes    map[string]DeltaKind
+	byItemId   map[int]indexedItem
+	mu         sync.RWMutex
+}
+
+// indexedItem is where an item is kept, its group and position in the
+// group's Items, and how it was counted in the summary, so removing it
+// uncounts exactly what adding it counted
+type indexedItem struct {
+	group string
+	idx   int
+	name  string
+	value common.HC
+	rare  bool
 }
 
 type InventorySummaryItem struct {
-	Quantity int
-	HCValue  float64
+	Quantity    int
+	HCValue     common.HC
+	PriceSource string
+	Rare        bool
+	Trend       common.PriceTrend
 }
 
 type InventorySummary struct {
 	TotalUniqueItems int
 	TotalItems       int
-	TotalWealth      float64
+	TotalWealth      common.HC
 	Items            map[string]InventorySummaryItem
+	PriceData        common.DataStatus
+	Rarity           common.RarityBreakdown
+	Stale            bool
+	CapturedAt       time.Time
 }
 
-func NewUIManager(ctx context.Context, ext *g.Ext, inventoryManager *inventory.Manager, roomManager *room.Manager, profileManager *profile.Manager, tradeManager *trading.Manager, startInventoryScanning func()) *UIManager {
+// add counts one item in the summary
+func (s *InventorySummary) add(item common.EnrichedInventoryItem, catalog *common.Catalog) {
+	s.TotalItems++
+	s.TotalWealth += item.HCValue
+	s.Rarity.Add(item.Rare, item.HCValue)
+
+	summaryItem, exists := s.Items[item.Name]
+	if !exists {
+		summaryItem.Trend = catalog.Trend(item.PriceKey())
+		summaryItem.Rare = item.Rare
+	}
+	summaryItem.Quantity++
+	summaryItem.HCValue += item.HCValue
+	summaryItem.PriceSource = item.PriceSource
+	s.Items[item.Name] = summaryItem
+}
+
+// remove uncounts one item counted by add
+func (s *InventorySummary) remove(item indexedItem) {
+	s.TotalItems--
+	s.TotalWealth -= item.value
+	s.Rarity.Remove(item.rare, item.value)
+
+	summaryItem := s.Items[item.name]
+	summaryItem.Quantity--
+	summaryItem.HCValue -= item.value
+	if summaryItem.Quantity == 0 {
+		delete(s.Items, item.name)
+	} else {
+		s.Items[item.name] = summaryItem
+	}
+}
+
+// NewUIManager creates a UIManager that shows and emits deltas of
+// unifiedInventory, the inventory the rest of the app reads and fills too
+func NewUIManager(ctx context.Context, ext *g.Ext, inventoryManager *inventory.Manager, unifiedInventory *UnifiedInventory, roomManager *room.Manager, profileManager *profile.Manager, tradeManager *trading.Manager, startInventoryScanning func()) *UIManager {
 	return &UIManager{
 		ctx:              ctx,
 		ext:              ext,
@@ -58,7 +147,7 @@ func NewUIManager(ctx context.Context, ext *g.Ext, inventoryManager *inventory.M
 		roomManager:      roomManager,
 		profileManager:   profileManager,
 		tradeManager:     tradeManager,
-		unifiedInventory: NewUnifiedInventory(),
+		unifiedInventory: unifiedInventory,
 	}
 }
 
@@ -68,6 +157,8 @@ func NewUnifiedInventory() *UnifiedInventory {
 		Summary: InventorySummary{
 			Items: make(map[string]InventorySummaryItem),
 		},
+		GroupBy:  GroupByVariant,
+		byItemId: make(map[int]indexedItem),
 	}
 }
 
@@ -75,8 +166,16 @@ func (ui *UnifiedInventory) AddItem(item inventory.Item) {
 	ui.mu.Lock()
 	defer ui.mu.Unlock()
 
+	ui.addItem(item)
+}
+
+// addItem adds item, replacing an item with the same id
+func (ui *UnifiedInventory) addItem(item inventory.Item) {
+	if _, ok := ui.byItemId[item.ItemId]; ok {
+		ui.removeItem(item.ItemId)
+	}
 	enrichedItem := common.EnrichInventoryItem(item)
-	groupKey := enrichedItem.GroupKey
+	groupKey := ui.groupKey(enrichedItem)
 	unifiedItem, exists := ui.Items[groupKey]
 	if !exists {
 		unifiedItem = UnifiedItem{
@@ -84,52 +183,160 @@ func (ui *UnifiedInventory) AddItem(item inventory.Item) {
 			EnrichedItem: enrichedItem,
 			Quantity:     1,
 			InTrade:      false,
+			Variants:     make(map[string]int),
 		}
 		ui.Summary.TotalUniqueItems++
+		ui.markChanged(groupKey, DeltaAdded)
 	} else {
 		unifiedItem.Items = append(unifiedItem.Items, item)
 		unifiedItem.Quantity++
+		ui.markChanged(groupKey, DeltaChanged)
+	}
+	if enrichedItem.Variant != "" {
+		unifiedItem.Variants[item.Class]++
 	}
 	ui.Items[groupKey] = unifiedItem
+	ui.byItemId[item.ItemId] = indexedItem{
+		group: groupKey,
+		idx:   len(unifiedItem.Items) - 1,
+		name:  enrichedItem.Name,
+		value: enrichedItem.HCValue,
+		rare:  enrichedItem.Rare,
+	}
+	ui.Summary.add(enrichedItem, common.DefaultCatalog())
+}
+
+// groupValue sums the values the items of a group were counted with. Groups
+// of base furni mix variants, so the group's own value does not apply to
+// every item.
+func (ui *UnifiedInventory) groupValue(unifiedItem UnifiedItem) common.HC {
+	var total common.HC
+	for _, item := range unifiedItem.Items {
+		total += ui.byItemId[item.ItemId].value
+	}
+	return total
+}
 
-	ui.Summary.TotalItems++
-	ui.Summary.TotalWealth += enrichedItem.HCValue
+// GroupKey returns the key of the group item belongs to
+func (ui *UnifiedInventory) GroupKey(item inventory.Item) string {
+	ui.mu.RLock()
+	defer ui.mu.RUnlock()
+	return ui.groupKey(common.EnrichInventoryItem(item))
+}
 
-	summaryItem := ui.Summary.Items[enrichedItem.Name]
-	summaryItem.Quantity++
-	summaryItem.HCValue += enrichedItem.HCValue
-	ui.Summary.Items[enrichedItem.Name] = summaryItem
+func (ui *UnifiedInventory) groupKey(item common.EnrichedInventoryItem) string {
+	if ui.GroupBy == GroupByBase {
+		return item.BaseGroupKey()
+	}
+	return item.GroupKey
 }
 
-func (ui *UnifiedInventory) RemoveItem(itemId int) {
+// SetGroupBy switches between grouping colour variants separately and
+// grouping them under their base furni, and regroups the inventory
+func (ui *UnifiedInventory) SetGroupBy(mode GroupMode) {
 	ui.mu.Lock()
 	defer ui.mu.Unlock()
 
+	if mode != GroupByBase {
+		mode = GroupByVariant
+	}
+	ui.GroupBy = mode
+	ui.rebuild()
+}
+
+// Rebuild re-enriches every item with the current default catalog, keeping
+// the trade status of each group
+func (ui *UnifiedInventory) Rebuild() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.rebuild()
+}
+
+func (ui *UnifiedInventory) rebuild() {
+	var items []inventory.Item
+	inTrade := make(map[int]bool)
+	for _, unifiedItem := range ui.Items {
+		items = append(items, unifiedItem.Items...)
+		if unifiedItem.InTrade {
+			for _, item := range unifiedItem.Items {
+				inTrade[item.ItemId] = true
+			}
+		}
+	}
+
+	ui.Items = make(map[string]UnifiedItem)
+	ui.byItemId = make(map[int]indexedItem)
+	ui.Summary = InventorySummary{
+		Items: make(map[string]InventorySummaryItem),
+	}
+	for _, item := range items {
+		ui.addItem(item)
+	}
 	for groupKey, unifiedItem := range ui.Items {
-		for i, item := range unifiedItem.Items {
-			if item.ItemId == itemId {
-				unifiedItem.Items = append(unifiedItem.Items[:i], unifiedItem.Items[i+1:]...)
-				unifiedItem.Quantity--
-
-				ui.Summary.TotalItems--
-				ui.Summary.TotalWealth -= unifiedItem.EnrichedItem.HCValue
-
-				summaryItem := ui.Summary.Items[unifiedItem.EnrichedItem.Name]
-				summaryItem.Quantity--
-				summaryItem.HCValue -= unifiedItem.EnrichedItem.HCValue
-
-				if unifiedItem.Quantity == 0 {
-					delete(ui.Items, groupKey)
-					ui.Summary.TotalUniqueItems--
-					delete(ui.Summary.Items, unifiedItem.EnrichedItem.Name)
-				} else {
-					ui.Items[groupKey] = unifiedItem
-					ui.Summary.Items[unifiedItem.EnrichedItem.Name] = summaryItem
-				}
-
-				return
+		for _, item := range unifiedItem.Items {
+			if inTrade[item.ItemId] {
+				unifiedItem.InTrade = true
+				break
 			}
 		}
+		ui.Items[groupKey] = unifiedItem
+	}
+	ui.changes = nil
+}
+
+// findItem looks up the group holding itemId through the index and returns
+// its key, the group and the item's position in it
+func (ui *UnifiedInventory) findItem(itemId int) (string, UnifiedItem, int, bool) {
+	indexed, ok := ui.byItemId[itemId]
+	if !ok {
+		return "", UnifiedItem{}, 0, false
+	}
+	return indexed.group, ui.Items[indexed.group], indexed.idx, true
+}
+
+func (ui *UnifiedInventory) RemoveItem(itemId int) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.removeItem(itemId)
+}
+
+// removeItem removes itemId by moving the group's last item into its place,
+// so removing does not depend on the size of the group
+func (ui *UnifiedInventory) removeItem(itemId int) {
+	groupKey, unifiedItem, i, ok := ui.findItem(itemId)
+	if !ok {
+		return
+	}
+	item := unifiedItem.Items[i]
+	ui.Summary.remove(ui.byItemId[itemId])
+	delete(ui.byItemId, itemId)
+
+	last := len(unifiedItem.Items) - 1
+	if i != last {
+		moved := unifiedItem.Items[last]
+		unifiedItem.Items[i] = moved
+		indexed := ui.byItemId[moved.ItemId]
+		indexed.idx = i
+		ui.byItemId[moved.ItemId] = indexed
+	}
+	unifiedItem.Items = unifiedItem.Items[:last]
+	unifiedItem.Quantity--
+	if _, ok := unifiedItem.Variants[item.Class]; ok {
+		unifiedItem.Variants[item.Class]--
+		if unifiedItem.Variants[item.Class] == 0 {
+			delete(unifiedItem.Variants, item.Class)
+		}
+	}
+
+	if unifiedItem.Quantity == 0 {
+		delete(ui.Items, groupKey)
+		ui.Summary.TotalUniqueItems--
+		ui.markChanged(groupKey, DeltaRemoved)
+	} else {
+		ui.Items[groupKey] = unifiedItem
+		ui.markChanged(groupKey, DeltaChanged)
 	}
 }
 
@@ -137,40 +344,203 @@ func (ui *UnifiedInventory) UpdateItemTradeStatus(itemId int, inTrade bool) {
 	ui.mu.Lock()
 	defer ui.mu.Unlock()
 
-	for groupKey, unifiedItem := range ui.Items {
+	indexed, ok := ui.byItemId[itemId]
+	if !ok {
+		return
+	}
+	groupKey := indexed.group
+	if unifiedItem := ui.Items[groupKey]; unifiedItem.InTrade != inTrade {
+		unifiedItem.InTrade = inTrade // Update the UnifiedItem's InTrade status
+		ui.Items[groupKey] = unifiedItem
+		ui.markChanged(groupKey, DeltaChanged)
+	}
+}
+
+// FindItem returns the item with itemId
+func (ui *UnifiedInventory) FindItem(itemId int) (inventory.Item, bool) {
+	ui.mu.RLock()
+	defer ui.mu.RUnlock()
+
+	_, unifiedItem, i, ok := ui.findItem(itemId)
+	if !ok {
+		return inventory.Item{}, false
+	}
+	return unifiedItem.Items[i], true
+}
+
+// InventoryItems returns every item of the inventory keyed by item id,
+// including the items of a restored snapshot
+func (ui *UnifiedInventory) InventoryItems() map[int]inventory.Item {
+	ui.mu.RLock()
+	defer ui.mu.RUnlock()
+	items := make(map[int]inventory.Item, len(ui.byItemId))
+	for _, unifiedItem := range ui.Items {
 		for _, item := range unifiedItem.Items {
-			if item.ItemId == itemId {
-				unifiedItem.InTrade = inTrade // Update the UnifiedItem's InTrade status
-				ui.Items[groupKey] = unifiedItem
-				return
-			}
+			items[item.ItemId] = item
 		}
 	}
+	return items
 }
 
 func (ui *UnifiedInventory) GetGroupedItems() map[string]UnifiedItem {
 	ui.mu.RLock()
 	defer ui.mu.RUnlock()
-	return ui.Items
+	groups := make(map[string]UnifiedItem, len(ui.Items))
+	for groupKey, unifiedItem := range ui.Items {
+		groups[groupKey] = unifiedItem.clone()
+	}
+	return groups
+}
+
+// computeSummary rebuilds the summary from the items alone, valuing each
+// item with the current default catalog
+func (ui *UnifiedInventory) computeSummary() InventorySummary {
+	summary := InventorySummary{
+		TotalUniqueItems: len(ui.Items),
+		Items:            make(map[string]InventorySummaryItem),
+	}
+	catalog := common.DefaultCatalog()
+	for _, unifiedItem := range ui.Items {
+		for _, item := range unifiedItem.Items {
+			summary.add(catalog.EnrichInventoryItem(item), catalog)
+		}
+	}
+	return summary
+}
+
+// SummaryDrift lists where the incrementally kept summary differs from one
+// recomputed from the items
+type SummaryDrift struct {
+	TotalItems       int
+	TotalUniqueItems int
+	TotalWealth      common.HC
+	RareWealth       common.HC
+	Items            []string
+}
+
+// OK reports whether the summary matched the items
+func (d SummaryDrift) OK() bool {
+	return d.TotalItems == 0 && d.TotalUniqueItems == 0 && d.TotalWealth == 0 && d.RareWealth == 0 && len(d.Items) == 0
+}
+
+// CheckSummary compares the summary with one recomputed from the items and
+// replaces it with the recomputed one if they differ. Drift means an update
+// was missed or prices changed without a Rebuild. It re-enriches every item,
+// so it is only run on request.
+func (ui *UnifiedInventory) CheckSummary() SummaryDrift {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	computed := ui.computeSummary()
+	drift := SummaryDrift{
+		TotalItems:       ui.Summary.TotalItems - computed.TotalItems,
+		TotalUniqueItems: ui.Summary.TotalUniqueItems - computed.TotalUniqueItems,
+		TotalWealth:      ui.Summary.TotalWealth - computed.TotalWealth,
+		RareWealth:       ui.Summary.Rarity.RareValue - computed.Rarity.RareValue,
+	}
+	for name, item := range ui.Summary.Items {
+		if other, ok := computed.Items[name]; !ok || other.Quantity != item.Quantity || other.HCValue != item.HCValue {
+			drift.Items = append(drift.Items, name)
+		}
+	}
+	for name := range computed.Items {
+		if _, ok := ui.Summary.Items[name]; !ok {
+			drift.Items = append(drift.Items, name)
+		}
+	}
+	sort.Strings(drift.Items)
+
+	if !drift.OK() {
+		ui.Summary = computed
+	}
+	return drift
 }
 
 func (ui *UnifiedInventory) GetSummary() InventorySummary {
 	ui.mu.RLock()
 	defer ui.mu.RUnlock()
-	return ui.Summary
+	return ui.summary()
+}
+
+// summary returns a copy of the summary that shares nothing with the
+// inventory. ui.mu must be held.
+func (ui *UnifiedInventory) summary() InventorySummary {
+	summary := ui.Summary
+	summary.PriceData = common.DefaultCatalog().Status().Prices
+	summary.Rarity.UnpricedRares = nil
+	summary.Items = make(map[string]InventorySummaryItem, len(ui.Summary.Items))
+	for name, item := range ui.Summary.Items {
+		summary.Items[name] = item
+		if item.Rare && item.PriceSource == "" {
+			summary.Rarity.UnpricedRares = append(summary.Rarity.UnpricedRares, name)
+		}
+	}
+	sort.Strings(summary.Rarity.UnpricedRares)
+	summary.Stale = ui.stale
+	summary.CapturedAt = ui.capturedAt
+	return summary
 }
 
-func (ui *UnifiedInventory) ItemExists(itemId int) bool {
+// Restore fills the inventory from a saved snapshot and marks it stale
+// until a fresh scan replaces it
+func (ui *UnifiedInventory) Restore(snapshot common.InventorySnapshot) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.reset()
+	for _, item := range snapshot.Items {
+		ui.addItem(item.Item)
+	}
+	ui.stale = true
+	ui.capturedAt = snapshot.CapturedAt
+	ui.changes = nil
+}
+
+// Reset empties the inventory for a new scan, keeping its grouping
+func (ui *UnifiedInventory) Reset() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.reset()
+}
+
+func (ui *UnifiedInventory) reset() {
+	for groupKey := range ui.Items {
+		ui.markChanged(groupKey, DeltaRemoved)
+	}
+	ui.Items = make(map[string]UnifiedItem)
+	ui.byItemId = make(map[int]indexedItem)
+	ui.Summary = InventorySummary{
+		Items: make(map[string]InventorySummaryItem),
+	}
+	ui.stale = false
+	ui.capturedAt = time.Time{}
+}
+
+// IsStale reports whether the inventory was restored from a snapshot and not
+// scanned since
+func (ui *UnifiedInventory) IsStale() bool {
 	ui.mu.RLock()
 	defer ui.mu.RUnlock()
+	return ui.stale
+}
+
+// PriceKeys returns the price key of every group in the inventory
+func (ui *UnifiedInventory) PriceKeys() []common.PriceKey {
+	ui.mu.RLock()
+	defer ui.mu.RUnlock()
+	keys := make([]common.PriceKey, 0, len(ui.Items))
 	for _, unifiedItem := range ui.Items {
-		for _, item := range unifiedItem.Items {
-			if item.ItemId == itemId {
-				return true
-			}
-		}
+		keys = append(keys, unifiedItem.EnrichedItem.PriceKey())
 	}
-	return false
+	return keys
+}
+
+func (ui *UnifiedInventory) ItemExists(itemId int) bool {
+	ui.mu.RLock()
+	defer ui.mu.RUnlock()
+	_, ok := ui.byItemId[itemId]
+	return ok
 }
 
 func (m *UIManager) HandleInventoryUpdate() {
@@ -178,37 +548,68 @@ func (m *UIManager) HandleInventoryUpdate() {
 	defer m.mu.Unlock()
 
 	items := m.inventoryManager.Items()
-	m.unifiedInventory = NewUnifiedInventory()
+	m.unifiedInventory.Reset()
 	for _, item := range items {
 		m.unifiedInventory.AddItem(item)
 	}
 
 	m.RefreshInventoryDisplay()
-	runtime.EventsEmit(m.ctx, "inventoryScanComplete")
+	eventsEmit(m.ctx, "inventoryScanComplete")
 }
 func (m *UIManager) RefreshInventorySummaryDisplay() {
 	summary := m.unifiedInventory.GetSummary()
-	runtime.EventsEmit(m.ctx, "inventorySummaryUpdated", summary)
+	eventsEmit(m.ctx, "inventorySummaryUpdated", summary)
+}
+
+// CheckInventorySummary recomputes the summary from the items, which
+// re-enriches every item, and emits it again if it had drifted
+func (m *UIManager) CheckInventorySummary() SummaryDrift {
+	drift := m.unifiedInventory.CheckSummary()
+	if !drift.OK() {
+		runtime.LogWarningf(m.ctx, "Inventory summary drifted from its items and was recomputed: %+v", drift)
+		m.RefreshInventorySummaryDisplay()
+	}
+	return drift
 }
 
 func (m *UIManager) RefreshInventoryIcons() {
 	groupedItems := m.unifiedInventory.GetGroupedItems()
-	runtime.EventsEmit(m.ctx, "inventoryIconsUpdated", groupedItems)
+	eventsEmit(m.ctx, "inventoryIconsUpdated", groupedItems)
 }
 
 func (m *UIManager) HandleItemAddition(item inventory.Item) {
 	m.unifiedInventory.AddItem(item)
-	m.RefreshInventoryDisplay()
+	m.emitInventoryDelta()
 }
 
 func (m *UIManager) HandleItemRemoval(itemId int) {
 	m.unifiedInventory.RemoveItem(itemId)
-	m.RefreshInventoryDisplay()
+	m.emitInventoryDelta()
 }
 
+// RefreshInventoryDisplay emits the summary and the whole inventory. Changes
+// to single items are emitted as deltas instead.
 func (m *UIManager) RefreshInventoryDisplay() {
 	m.RefreshInventorySummaryDisplay()
-	m.RefreshInventoryIcons()
+	m.emitInventoryResync()
+}
+
+// RefreshEnrichment re-enriches the inventory and room with the current
+// default catalog and emits the updated views
+func (m *UIManager) RefreshEnrichment() {
+	m.mu.Lock()
+	m.unifiedInventory.Rebuild()
+	m.mu.Unlock()
+
+	m.RefreshInventoryDisplay()
+	m.UpdateRoomDisplay(m.roomManager.Objects, m.roomManager.Items)
+}
+
+// SetInventoryGrouping regroups the inventory by variant or base furni and
+// emits the updated views
+func (m *UIManager) SetInventoryGrouping(mode GroupMode) {
+	m.unifiedInventory.SetGroupBy(mode)
+	m.RefreshInventoryDisplay()
 }
 
 func (m *UIManager) HandleTradeUpdated(args trade.Args) {
@@ -216,10 +617,57 @@ func (m *UIManager) HandleTradeUpdated(args trade.Args) {
 		Trader: args.Offers[0],
 		Tradee: args.Offers[1],
 	}
-	runtime.EventsEmit(m.ctx, "tradeUpdate", offers)
+	eventsEmit(m.ctx, "tradeUpdate", offers)
+	valuation := TradeValuation{
+		TraderName: args.Offers[0].Name,
+		TradeeName: args.Offers[1].Name,
+		Trader:     valueOffer(args.Offers[0]),
+		Tradee:     valueOffer(args.Offers[1]),
+	}
+	m.mu.Lock()
+	m.lastTrade = &valuation
+	m.mu.Unlock()
+	eventsEmit(m.ctx, "tradeValuation", valuation)
+}
+
+// TradeValuation is the value of both sides of a trade
+type TradeValuation struct {
+	TraderName string
+	TradeeName string
+	Trader     common.Valuation
+	Tradee     common.Valuation
+}
+
+// LastTradeValuation returns the valuation of the most recently updated
+// trade, or false if no trade has been seen
+func (m *UIManager) LastTradeValuation() (TradeValuation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lastTrade == nil {
+		return TradeValuation{}, false
+	}
+	return *m.lastTrade, true
+}
+
+// TradeReport describes both sides of the most recently updated trade as
+// Discord embeds
+func (m *UIManager) TradeReport() ([]common.Embed, error) {
+	valuation, ok := m.LastTradeValuation()
+	if !ok {
+		return nil, fmt.Errorf("no trade to report")
+	}
+	return common.TradeEmbeds(valuation.TraderName, valuation.Trader, valuation.TradeeName, valuation.Tradee), nil
+}
+
+func valueOffer(offer trade.Offer) common.Valuation {
+	items := make([]common.EnrichedInventoryItem, 0, len(offer.Items))
+	for _, item := range offer.Items {
+		items = append(items, common.EnrichInventoryItem(item))
+	}
+	return common.ValueItems(items)
 }
 func (m *UIManager) HandleTradeAccepted(args trade.AcceptArgs) {
-	runtime.EventsEmit(m.ctx, "tradeAccepted", args)
+	eventsEmit(m.ctx, "tradeAccepted", args)
 }
 
 func (m *UIManager) HandleTradeCompleted(args trade.Args) {
@@ -233,27 +681,27 @@ func (m *UIManager) HandleTradeCompleted(args trade.Args) {
 		m.unifiedInventory.AddItem(item)
 	}
 
-	m.RefreshInventorySummaryDisplay()
-	m.RefreshInventoryIcons()
-	runtime.EventsEmit(m.ctx, "tradeCompleted", args)
+	m.emitInventoryDelta()
+	eventsEmit(m.ctx, "tradeCompleted", args)
 }
 func (m *UIManager) HandleTradeClosed(args trade.Args) {
 	// Reset trade status for all items
-	for _, unifiedItem := range m.unifiedInventory.Items {
+	for _, unifiedItem := range m.unifiedInventory.GetGroupedItems() {
 		for _, item := range unifiedItem.Items {
 			m.unifiedInventory.UpdateItemTradeStatus(item.ItemId, false)
 		}
 	}
-	runtime.EventsEmit(m.ctx, "tradeClosed", args)
+	m.emitInventoryDelta()
+	eventsEmit(m.ctx, "tradeClosed", args)
 }
 
 func (m *UIManager) AddItemToRoom(item room.Object) {
 	enrichedObject := common.EnrichRoomObject(item)
-	runtime.EventsEmit(m.ctx, "roomItemAdded", enrichedObject)
+	eventsEmit(m.ctx, "roomItemAdded", enrichedObject)
 }
 
 func (m *UIManager) RemoveItemFromRoom(itemId int) {
-	runtime.EventsEmit(m.ctx, "roomItemRemoved", itemId)
+	eventsEmit(m.ctx, "roomItemRemoved", itemId)
 }
 
 func (m *UIManager) UpdateRoomDisplay(objects map[int]room.Object, items map[int]room.Item) {
@@ -267,38 +715,26 @@ func (m *UIManager) UpdateRoomDisplay(objects map[int]room.Object, items map[int
 		enrichedItems = append(enrichedItems, common.EnrichRoomItem(item))
 	}
 
-	runtime.EventsEmit(m.ctx, "roomUpdate", enrichedObjects, enrichedItems)
+	eventsEmit(m.ctx, "roomUpdate", enrichedObjects, enrichedItems)
 }
 
 func (m *UIManager) CaptureRoom() {
 	objects := m.roomManager.Objects
 	items := m.roomManager.Items
 	summary := common.GetRoomSummary(objects, items)
-	runtime.EventsEmit(m.ctx, "roomCaptured", summary)
+	eventsEmit(m.ctx, "roomCaptured", summary)
 }
 
 func (m *UIManager) AcceptTrade() {
 	m.tradeManager.Accept()
-	runtime.EventsEmit(m.ctx, "tradeAccepted")
+	eventsEmit(m.ctx, "tradeAccepted")
 }
 
 func (m *UIManager) OfferItem(itemId int) {
 	m.tradeManager.Offer(itemId)
 	m.unifiedInventory.UpdateItemTradeStatus(itemId, true)
-	runtime.EventsEmit(m.ctx, "itemOffered", itemId)
-}
-
-func (m *UIManager) UpdateInventoryDisplay(items map[int]inventory.Item) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.unifiedInventory = NewUnifiedInventory()
-	for _, item := range items {
-		m.unifiedInventory.AddItem(item)
-	}
-
-	m.RefreshInventorySummaryDisplay()
-	m.RefreshInventoryIcons()
+	m.emitInventoryDelta()
+	eventsEmit(m.ctx, "itemOffered", itemId)
 }
 
 func (m *UIManager) UpdateInventoryItem(item inventory.Item, isAddition bool) {
@@ -311,16 +747,13 @@ func (m *UIManager) UpdateInventoryItem(item inventory.Item, isAddition bool) {
 		m.unifiedInventory.RemoveItem(item.ItemId)
 	}
 
-	// Get updated summary and grouped items
-	summary := m.unifiedInventory.GetSummary()
-	groupedItems := m.unifiedInventory.GetGroupedItems()
-
-	// Emit the update event
-	runtime.EventsEmit(m.ctx, "inventoryItemUpdated", map[string]interface{}{
-		"summary":      summary,
-		"groupedItems": groupedItems,
-		"updatedItem":  item,
-		"isAddition":   isAddition,
+	// The changed group goes out as a delta, the event only says which
+	// item caused it
+	m.emitInventoryDelta()
+	eventsEmit(m.ctx, "inventoryItemUpdated", map[string]interface{}{
+		"updatedItem": item,
+		"groupKey":    m.unifiedInventory.GroupKey(item),
+		"isAddition":  isAddition,
 	})
 
 	runtime.LogInfof(m.ctx, "Inventory item updated: %+v, isAddition: %v", item, isAddition)
@@ -330,12 +763,5 @@ func (m *UIManager) FindItemById(itemId int) (inventory.Item, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, unifiedItem := range m.unifiedInventory.Items {
-		for _, item := range unifiedItem.Items {
-			if item.ItemId == itemId {
-				return item, true
-			}
-		}
-	}
-	return inventory.Item{}, false
+	return m.unifiedInventory.FindItem(itemId)
 }
@@ -0,0 +1,293 @@
+package ui
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bolognesandwiches/G-itemViewer/common"
+	"xabbo.b7c.io/goearth/shockwave/inventory"
+)
+
+// useCatalog makes a catalog of a few furni with the given throne price the
+// default for the rest of the test
+func useCatalog(t *testing.T, thronePrice common.HC) {
+	t.Helper()
+	furni := []common.FurniData{
+		{ClassName: "throne", Name: "Throne", Rare: true},
+		{ClassName: "chair_polyfon", Name: "Dining Chair"},
+		{ClassName: "chair_polyfon*4", Name: "Red Dining Chair"},
+	}
+	items := []common.APIItem{
+		{Name: "Throne", Slug: "throne", HCVal: thronePrice},
+		{Name: "Dining Chair", Slug: "dining-chair", HCVal: 25},
+		{Name: "Red Dining Chair", Slug: "red-dining-chair", HCVal: 100},
+	}
+	c, err := common.NewCatalog(common.NewMemorySource(furni, nil, items))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := common.DefaultCatalog()
+	common.SetDefaultCatalog(c)
+	t.Cleanup(func() { common.SetDefaultCatalog(previous) })
+}
+
+func TestRemoveItemUncountsAddedValue(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+	inv.AddItem(inventory.Item{ItemId: 2, Class: "throne", Type: "S"})
+	inv.AddItem(inventory.Item{ItemId: 3, Class: "chair_polyfon", Type: "S"})
+
+	// A price update between adding and removing must not leave the totals
+	// off by the difference
+	useCatalog(t, 9000)
+	inv.RemoveItem(1)
+
+	summary := inv.GetSummary()
+	if summary.TotalItems != 2 || summary.TotalWealth != 5025 {
+		t.Errorf("%d items worth %s, want 2 worth 50.25", summary.TotalItems, summary.TotalWealth)
+	}
+	if summary.Rarity.RareValue != 5000 || summary.Items["Throne"].HCValue != 5000 {
+		t.Errorf("rare value %s, throne value %s", summary.Rarity.RareValue, summary.Items["Throne"].HCValue)
+	}
+
+	inv.RemoveItem(2)
+	inv.RemoveItem(3)
+	summary = inv.GetSummary()
+	if summary.TotalItems != 0 || summary.TotalWealth != 0 || len(summary.Items) != 0 {
+		t.Errorf("empty inventory has summary %+v", summary)
+	}
+}
+
+func TestCheckSummary(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+	if drift := inv.CheckSummary(); !drift.OK() {
+		t.Fatalf("drift right after adding: %+v", drift)
+	}
+
+	useCatalog(t, 9000)
+	drift := inv.CheckSummary()
+	if drift.TotalWealth != -4000 || len(drift.Items) != 1 {
+		t.Errorf("drift %+v", drift)
+	}
+	if summary := inv.GetSummary(); summary.TotalWealth != 9000 {
+		t.Errorf("summary not recomputed: %s", summary.TotalWealth)
+	}
+}
+
+func TestSummaryIsCopy(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+
+	summary := inv.GetSummary()
+	_, state := inv.State()
+	inv.AddItem(inventory.Item{ItemId: 2, Class: "throne", Type: "S"})
+	inv.AddItem(inventory.Item{ItemId: 3, Class: "chair_polyfon", Type: "S"})
+	if len(summary.Items) != 1 || summary.Items["Throne"].Quantity != 1 || state.Items["Throne"].Quantity != 1 {
+		t.Errorf("summary changed with the inventory: %+v, %+v", summary.Items, state.Items)
+	}
+
+	summary.Items["Throne"] = InventorySummaryItem{Quantity: 99}
+	if inv.GetSummary().Items["Throne"].Quantity != 2 {
+		t.Error("changing a summary changed the inventory")
+	}
+
+	// Run with -race: summaries are read while items are added
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			for range inv.GetSummary().Items {
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		inv.AddItem(inventory.Item{ItemId: 10 + i, Class: fmt.Sprintf("furni_%d", i), Type: "S"})
+	}
+	<-done
+}
+
+func TestResetKeepsGrouping(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.SetGroupBy(GroupByBase)
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+	inv.TakeDelta()
+
+	inv.Reset()
+	if inv.GroupBy != GroupByBase {
+		t.Errorf("grouping reset to %s", inv.GroupBy)
+	}
+	if inv.ItemExists(1) || len(inv.GetGroupedItems()) != 0 || inv.GetSummary().TotalItems != 0 {
+		t.Error("items kept after Reset")
+	}
+	changes, _ := inv.TakeDelta()
+	if len(changes) != 1 || changes[0].Kind != DeltaRemoved || changes[0].GroupKey != "throne" {
+		t.Errorf("changes %+v, want throne removed", changes)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	useCatalog(t, 5000)
+	items := map[int]inventory.Item{
+		1: {ItemId: 1, Class: "throne", Type: "S"},
+		2: {ItemId: 2, Class: "chair_polyfon", Type: "S"},
+		3: {ItemId: 3, Class: "chair_polyfon", Type: "S"},
+	}
+	snapshot := common.NewInventorySnapshot("alice", items)
+	snapshot.CapturedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	inv := NewUnifiedInventory()
+	inv.AddItem(inventory.Item{ItemId: 9, Class: "throne", Type: "S"})
+	inv.Restore(snapshot)
+	if !inv.IsStale() {
+		t.Error("restored inventory is not stale")
+	}
+	summary := inv.GetSummary()
+	if !summary.Stale || !summary.CapturedAt.Equal(snapshot.CapturedAt) || summary.TotalItems != 3 || summary.TotalWealth != 5050 {
+		t.Errorf("summary %+v", summary)
+	}
+
+	// Reports and new snapshots are built from the restored items until a
+	// scan replaces them
+	if got := inv.InventoryItems(); !reflect.DeepEqual(got, items) {
+		t.Errorf("inventory items %+v, want %+v", got, items)
+	}
+	again := common.NewInventorySnapshot("alice", inv.InventoryItems())
+	if !reflect.DeepEqual(again.Items, snapshot.Items) || again.TotalValue != snapshot.TotalValue {
+		t.Errorf("snapshot of the restored inventory:\n %+v\nwant %+v", again.Items, snapshot.Items)
+	}
+
+	inv.Reset()
+	if inv.IsStale() || len(inv.InventoryItems()) != 0 {
+		t.Error("restored snapshot kept after Reset")
+	}
+}
+
+// checkIndex fails the test unless every item is indexed at its group and
+// position and nothing else is indexed
+func checkIndex(t *testing.T, inv *UnifiedInventory) {
+	t.Helper()
+	count := 0
+	for groupKey, unifiedItem := range inv.Items {
+		if unifiedItem.Quantity != len(unifiedItem.Items) {
+			t.Errorf("%s: quantity %d with %d items", groupKey, unifiedItem.Quantity, len(unifiedItem.Items))
+		}
+		for i, item := range unifiedItem.Items {
+			indexed, ok := inv.byItemId[item.ItemId]
+			if !ok || indexed.group != groupKey || indexed.idx != i {
+				t.Errorf("item %d at %s[%d] is indexed as %+v", item.ItemId, groupKey, i, indexed)
+			}
+			count++
+		}
+	}
+	if count != len(inv.byItemId) || count != inv.Summary.TotalItems {
+		t.Errorf("%d items, %d indexed, summary counts %d", count, len(inv.byItemId), inv.Summary.TotalItems)
+	}
+}
+
+func TestItemIndex(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	for _, item := range scanItems(200) {
+		inv.AddItem(item)
+	}
+	checkIndex(t, inv)
+
+	// First, middle and last of a group, and an id that is not there
+	for _, itemId := range []int{1, 101, 196, 9999} {
+		inv.RemoveItem(itemId)
+		checkIndex(t, inv)
+		if inv.ItemExists(itemId) {
+			t.Errorf("item %d still exists", itemId)
+		}
+	}
+	if item, ok := inv.FindItem(11); !ok || item.ItemId != 11 {
+		t.Errorf("FindItem(11) = %+v, %v", item, ok)
+	}
+
+	inv.UpdateItemTradeStatus(11, true)
+	inv.SetGroupBy(GroupByBase)
+	checkIndex(t, inv)
+	inv.RemoveItem(11)
+	checkIndex(t, inv)
+	if inv.Summary.TotalItems != 196 {
+		t.Errorf("%d items left, want 196", inv.Summary.TotalItems)
+	}
+}
+
+func TestAddItemReplacesSameId(t *testing.T) {
+	useCatalog(t, 5000)
+	inv := NewUnifiedInventory()
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "chair_polyfon", Type: "S"})
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "chair_polyfon", Type: "S"})
+	inv.AddItem(inventory.Item{ItemId: 1, Class: "throne", Type: "S"})
+	checkIndex(t, inv)
+
+	summary := inv.GetSummary()
+	if summary.TotalItems != 1 || summary.TotalUniqueItems != 1 || summary.TotalWealth != 5000 {
+		t.Errorf("summary %+v, want the throne alone", summary)
+	}
+	if _, ok := inv.Items["chair_polyfon"]; ok {
+		t.Error("replaced item's group was kept")
+	}
+}
+
+// scanItems makes n items spread over 20 groups of furni
+func scanItems(n int) []inventory.Item {
+	items := make([]inventory.Item, n)
+	for i := range items {
+		items[i] = inventory.Item{ItemId: i + 1, Class: fmt.Sprintf("furni_%d", i%20), Type: "S"}
+	}
+	return items
+}
+
+func BenchmarkAddItems10k(b *testing.B) {
+	items := scanItems(10000)
+	for n := 0; n < b.N; n++ {
+		inv := NewUnifiedInventory()
+		for _, item := range items {
+			inv.AddItem(item)
+		}
+	}
+}
+
+// BenchmarkScan10k adds items the way a scan does, checking every item of
+// each page received so far
+func BenchmarkScan10k(b *testing.B) {
+	items := scanItems(10000)
+	for n := 0; n < b.N; n++ {
+		inv := NewUnifiedInventory()
+		for page := 100; page <= len(items); page += 100 {
+			for _, item := range items[:page] {
+				if !inv.ItemExists(item.ItemId) {
+					inv.AddItem(item)
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkRemoveItems10k removes every item of one 10k item group
+func BenchmarkRemoveItems10k(b *testing.B) {
+	items := make([]inventory.Item, 10000)
+	for i := range items {
+		items[i] = inventory.Item{ItemId: i + 1, Class: "chair_polyfon", Type: "S"}
+	}
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		inv := NewUnifiedInventory()
+		for _, item := range items {
+			inv.AddItem(item)
+		}
+		b.StartTimer()
+		for _, item := range items {
+			inv.RemoveItem(item.ItemId)
+		}
+	}
+}